@@ -9,9 +9,13 @@ import (
 	"net/url"
 	"strconv"
 
+	"github.com/Financial-Times/annotations-publisher/breaker"
 	"github.com/Financial-Times/annotations-publisher/health"
+	"github.com/Financial-Times/annotations-publisher/tracing"
 	"github.com/Financial-Times/go-logger/v2"
 	status "github.com/Financial-Times/service-status-go/httphandlers"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -19,10 +23,27 @@ const (
 	PreviousDocumentHashHeader = "Previous-Document-Hash"
 )
 
+// StatusError occurs when the r/w service responds with an unexpected http status
+type StatusError struct {
+	Operation  string
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v %v returned a %v status code", e.Operation, e.URL, e.StatusCode)
+}
+
+// Is makes errors.Is(err, ErrConflict) true for writes rejected because of a Previous-Document-Hash mismatch
+func (e *StatusError) Is(target error) bool {
+	return target == ErrConflict && (e.StatusCode == http.StatusConflict || e.StatusCode == http.StatusPreconditionFailed)
+}
+
 type AnnotationsClient interface {
 	health.ExternalService
 	GetAnnotations(ctx context.Context, uuid string) (AnnotationsBody, string, error)
 	SaveAnnotations(ctx context.Context, uuid string, hash string, data AnnotationsBody) (AnnotationsBody, string, error)
+	DeleteAnnotations(ctx context.Context, uuid string) error
 }
 
 type genericRWClient struct {
@@ -45,7 +66,8 @@ func NewAnnotationsClient(endpoint string, client *http.Client, log *logger.UPPL
 }
 
 func (rw *genericRWClient) GTG() error {
-	req, err := http.NewRequest("GET", rw.gtgEndpoint, nil)
+	// the breaker only tracks the reads and writes of annotations, so a healthy __gtg cannot close it while they fail
+	req, err := http.NewRequestWithContext(breaker.Bypass(context.Background()), "GET", rw.gtgEndpoint, nil)
 	if err != nil {
 		rw.log.WithError(err).WithField("healthEndpoint", rw.gtgEndpoint).Error("Error in creating GTG request for generic-rw-aurora")
 		return err
@@ -74,6 +96,13 @@ func (rw *genericRWClient) Endpoint() string {
 }
 
 func (rw *genericRWClient) GetAnnotations(ctx context.Context, uuid string) (AnnotationsBody, string, error) {
+	ctx, span := rw.startSpan(ctx, "GetAnnotations", uuid)
+	ann, hash, err := rw.getAnnotations(ctx, uuid)
+	tracing.End(span, err)
+	return ann, hash, err
+}
+
+func (rw *genericRWClient) getAnnotations(ctx context.Context, uuid string) (AnnotationsBody, string, error) {
 	draftsURL := fmt.Sprintf(rw.rwEndpoint, uuid)
 	req, err := http.NewRequest("GET", draftsURL, nil)
 	if err != nil {
@@ -99,7 +128,7 @@ func (rw *genericRWClient) GetAnnotations(ctx context.Context, uuid string) (Ann
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return AnnotationsBody{}, "", fmt.Errorf("read from %v returned a %v status code", draftsURL, resp.StatusCode)
+		return AnnotationsBody{}, "", &StatusError{Operation: "read from", URL: draftsURL, StatusCode: resp.StatusCode}
 	}
 
 	hash := resp.Header.Get(DocumentHashHeader)
@@ -110,6 +139,13 @@ func (rw *genericRWClient) GetAnnotations(ctx context.Context, uuid string) (Ann
 }
 
 func (rw *genericRWClient) SaveAnnotations(ctx context.Context, uuid string, hash string, data AnnotationsBody) (AnnotationsBody, string, error) {
+	ctx, span := rw.startSpan(ctx, "SaveAnnotations", uuid)
+	ann, newHash, err := rw.saveAnnotations(ctx, uuid, hash, data)
+	tracing.End(span, err)
+	return ann, newHash, err
+}
+
+func (rw *genericRWClient) saveAnnotations(ctx context.Context, uuid string, hash string, data AnnotationsBody) (AnnotationsBody, string, error) {
 	draftsURL := fmt.Sprintf(rw.rwEndpoint, uuid)
 	body, err := json.Marshal(data)
 	if err != nil {
@@ -142,5 +178,39 @@ func (rw *genericRWClient) SaveAnnotations(ctx context.Context, uuid string, has
 		return ann, resp.Header.Get(DocumentHashHeader), err
 	}
 
-	return AnnotationsBody{}, "", fmt.Errorf("write to %v returned a %v status code", draftsURL, resp.StatusCode)
+	return AnnotationsBody{}, "", &StatusError{Operation: "write to", URL: draftsURL, StatusCode: resp.StatusCode}
+}
+
+func (rw *genericRWClient) DeleteAnnotations(ctx context.Context, uuid string) error {
+	ctx, span := rw.startSpan(ctx, "DeleteAnnotations", uuid)
+	err := rw.deleteAnnotations(ctx, uuid)
+	tracing.End(span, err)
+	return err
+}
+
+func (rw *genericRWClient) deleteAnnotations(ctx context.Context, uuid string) error {
+	draftsURL := fmt.Sprintf(rw.rwEndpoint, uuid)
+	req, err := http.NewRequest("DELETE", draftsURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := rw.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return ErrDraftNotFound
+	}
+	return &StatusError{Operation: "delete from", URL: draftsURL, StatusCode: resp.StatusCode}
+}
+
+// startSpan starts a span of a request to the r/w service, whose trace context is sent with the request
+func (rw *genericRWClient) startSpan(ctx context.Context, operation string, uuid string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, tracer, operation, uuid, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.String("url.full", fmt.Sprintf(rw.rwEndpoint, uuid))))
 }
@@ -356,3 +356,51 @@ func TestSaveAnnotationsWriterReturnsNoBody(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, testAnnotations, actual)
 }
+
+func TestDeleteAnnotations(t *testing.T) {
+	testTid := "tid_test"
+	testCtx := tid.TransactionAwareContext(context.Background(), testTid)
+	testUUID := uuid.New()
+
+	tests := map[string]struct {
+		status   int
+		expected string
+	}{
+		"deleted":        {status: http.StatusNoContent},
+		"not found":      {status: http.StatusNotFound},
+		"service failed": {status: http.StatusInternalServerError, expected: "delete from %s returned a 500 status code"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := vestigo.NewRouter()
+			r.Delete(draftsURL, func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, testTid, r.Header.Get(tid.TransactionIDHeader), "transaction id")
+				assert.Equal(t, testUUID, vestigo.Param(r, "uuid"))
+				w.WriteHeader(test.status)
+			})
+
+			server := httptest.NewServer(r)
+			defer server.Close()
+
+			testingClient, err := fthttp.NewClient(
+				fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+			)
+			require.NoError(t, err)
+
+			annotationsURL := server.URL + "/drafts/content/%s/annotations"
+			client, err := NewAnnotationsClient(annotationsURL, testingClient, logger.NewUPPLogger("test", "DEBUG"))
+			require.NoError(t, err)
+
+			err = client.DeleteAnnotations(testCtx, testUUID)
+			switch test.status {
+			case http.StatusNoContent:
+				assert.NoError(t, err)
+			case http.StatusNotFound:
+				assert.Equal(t, ErrDraftNotFound, err)
+			default:
+				assert.EqualError(t, err, fmt.Sprintf(test.expected, fmt.Sprintf(annotationsURL, testUUID)))
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package annotations
+
+// AnnotationsDiff describes the changes between two versions of the annotations of a piece of content
+type AnnotationsDiff struct {
+	Added   []Annotation       `json:"added"`
+	Removed []Annotation       `json:"removed"`
+	Changed []AnnotationChange `json:"changed"`
+}
+
+// AnnotationChange is an annotation with the same predicate and concept in both versions, whose properties differ
+type AnnotationChange struct {
+	Predicate string     `json:"predicate"`
+	ConceptID string     `json:"id"`
+	From      Annotation `json:"from"`
+	To        Annotation `json:"to"`
+}
+
+// Empty reports whether the two versions hold the same annotations
+func (d AnnotationsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff returns the annotations added, removed and changed by to, compared with from.
+// Annotations with the same predicate and concept are the same annotation.
+func Diff(from AnnotationsBody, to AnnotationsBody) AnnotationsDiff {
+	fromByKey := annotationsByKey(from)
+	toByKey := annotationsByKey(to)
+
+	diff := AnnotationsDiff{Added: []Annotation{}, Removed: []Annotation{}, Changed: []AnnotationChange{}}
+	for _, ann := range to.Annotations {
+		previous, ok := fromByKey[annotationKey(ann)]
+		if !ok {
+			diff.Added = append(diff.Added, ann)
+			continue
+		}
+		if annotationChanged(previous, ann) {
+			diff.Changed = append(diff.Changed, AnnotationChange{Predicate: ann.Predicate, ConceptID: ann.ConceptID, From: previous, To: ann})
+		}
+	}
+	for _, ann := range from.Annotations {
+		if _, ok := toByKey[annotationKey(ann)]; !ok {
+			diff.Removed = append(diff.Removed, ann)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,31 @@
+package annotations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	about := Annotation{Predicate: "about", ConceptID: "a"}
+	author := Annotation{Predicate: "hasAuthor", ConceptID: "c"}
+	person := Annotation{Predicate: "mentions", ConceptID: "b", Type: "PERSON"}
+	organisation := Annotation{Predicate: "mentions", ConceptID: "b", Type: "ORGANISATION"}
+
+	diff := Diff(AnnotationsBody{Annotations: []Annotation{about, person}}, AnnotationsBody{Annotations: []Annotation{organisation, author}})
+
+	assert.Equal(t, []Annotation{author}, diff.Added)
+	assert.Equal(t, []Annotation{about}, diff.Removed)
+	assert.Equal(t, []AnnotationChange{{Predicate: "mentions", ConceptID: "b", From: person, To: organisation}}, diff.Changed)
+	assert.False(t, diff.Empty())
+}
+
+func TestDiffIgnoresEnrichment(t *testing.T) {
+	mentions := Annotation{Predicate: "mentions", ConceptID: "b"}
+	enriched := Annotation{Predicate: "mentions", ConceptID: "b", Type: "PERSON", PrefLabel: "Someone"}
+
+	diff := Diff(AnnotationsBody{Annotations: []Annotation{mentions}}, AnnotationsBody{Annotations: []Annotation{enriched}})
+
+	assert.True(t, diff.Empty())
+	assert.NotNil(t, diff.Added, "empty changes should be serialised as empty arrays")
+}
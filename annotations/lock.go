@@ -0,0 +1,74 @@
+package annotations
+
+import (
+	"context"
+	"sync"
+)
+
+// PublishLocker serialises the publishes of a piece of content. Deployments with several instances need an implementation shared by all of them.
+type PublishLocker interface {
+	// Lock blocks until the caller holds the lock of the key, or ctx is done. The returned function releases the lock.
+	Lock(ctx context.Context, key string) (func(), error)
+}
+
+// KeyedLocker is a PublishLocker for publishes made by a single instance. Callers waiting for the same key are granted the lock in the order they asked for it.
+type KeyedLocker struct {
+	mutex sync.Mutex
+	// waiters holds the callers of every locked key, the first of which holds the lock
+	waiters map[string][]chan struct{}
+}
+
+// NewKeyedLocker returns a KeyedLocker without any locked keys
+func NewKeyedLocker() *KeyedLocker {
+	return &KeyedLocker{waiters: make(map[string][]chan struct{})}
+}
+
+func (l *KeyedLocker) Lock(ctx context.Context, key string) (func(), error) {
+	granted := make(chan struct{})
+
+	l.mutex.Lock()
+	queue := l.waiters[key]
+	if len(queue) == 0 {
+		close(granted)
+	}
+	l.waiters[key] = append(queue, granted)
+	l.mutex.Unlock()
+
+	select {
+	case <-granted:
+		var once sync.Once
+		return func() { once.Do(func() { l.unlock(key) }) }, nil
+	case <-ctx.Done():
+	}
+
+	l.mutex.Lock()
+	select {
+	case <-granted:
+		// the lock was granted while giving up on it, so it is handed to the next caller
+		l.mutex.Unlock()
+		l.unlock(key)
+	default:
+		queue := l.waiters[key]
+		for i, ch := range queue {
+			if ch == granted {
+				l.waiters[key] = append(queue[:i:i], queue[i+1:]...)
+				break
+			}
+		}
+		l.mutex.Unlock()
+	}
+	return nil, ctx.Err()
+}
+
+func (l *KeyedLocker) unlock(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	queue := l.waiters[key][1:]
+	if len(queue) == 0 {
+		delete(l.waiters, key)
+		return
+	}
+	l.waiters[key] = queue
+	close(queue[0])
+}
@@ -0,0 +1,69 @@
+package annotations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedLockerGrantsLocksInOrder(t *testing.T) {
+	l := NewKeyedLocker()
+	ctx := context.Background()
+
+	unlock, err := l.Lock(ctx, "uuid")
+	require.NoError(t, err)
+
+	otherUnlock, err := l.Lock(ctx, "other-uuid")
+	require.NoError(t, err, "other keys should not be blocked")
+	otherUnlock()
+
+	order := make(chan int, 3)
+	for i := 1; i <= 3; i++ {
+		go func(i int) {
+			unlock, err := l.Lock(ctx, "uuid")
+			if err != nil {
+				return
+			}
+			order <- i
+			unlock()
+		}(i)
+		// wait for the goroutine to queue for the lock
+		require.Eventually(t, func() bool {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			return len(l.waiters["uuid"]) == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	unlock()
+	unlock() // releasing twice is harmless
+	assert.Equal(t, 1, <-order)
+	assert.Equal(t, 2, <-order)
+	assert.Equal(t, 3, <-order)
+
+	assert.Eventually(t, func() bool {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		return len(l.waiters) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestKeyedLockerTimeout(t *testing.T) {
+	l := NewKeyedLocker()
+
+	unlock, err := l.Lock(context.Background(), "uuid")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.Lock(ctx, "uuid")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	unlock()
+	unlock, err = l.Lock(context.Background(), "uuid")
+	require.NoError(t, err, "the caller which gave up should not hold the lock")
+	unlock()
+}
@@ -0,0 +1,184 @@
+package annotations
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrVersionNotFound occurs when the base version of a merge is not known
+var ErrVersionNotFound = errors.New("draft annotations version was not found")
+
+// DraftVersions provides earlier versions of the draft annotations by their Document-Hash, which are the base of a three-way merge
+type DraftVersions interface {
+	GetVersion(ctx context.Context, uuid string, hash string) (AnnotationsBody, error)
+}
+
+// MergeConflict is an annotation which was changed in incompatible ways by the submitted and the current draft annotations
+type MergeConflict struct {
+	Predicate string `json:"predicate"`
+	ConceptID string `json:"id"`
+	// Submitted is nil if the submitted annotations removed the annotation
+	Submitted *Annotation `json:"submitted"`
+	// Current is nil if the current draft annotations removed the annotation
+	Current *Annotation `json:"current"`
+}
+
+// VersionRecordingClient decorates an AnnotationsClient by keeping the most recent versions of the annotations it reads and writes,
+// so they can be used as the base of a three-way merge
+type VersionRecordingClient struct {
+	AnnotationsClient
+	size int
+
+	mutex    sync.Mutex
+	versions map[string]AnnotationsBody
+	order    []string
+}
+
+// NewVersionRecordingClient returns a VersionRecordingClient which keeps at most size versions, dropping the oldest first
+func NewVersionRecordingClient(client AnnotationsClient, size int) *VersionRecordingClient {
+	return &VersionRecordingClient{AnnotationsClient: client, size: size, versions: make(map[string]AnnotationsBody)}
+}
+
+func (vc *VersionRecordingClient) GetAnnotations(ctx context.Context, uuid string) (AnnotationsBody, string, error) {
+	ann, hash, err := vc.AnnotationsClient.GetAnnotations(ctx, uuid)
+	if err == nil {
+		vc.record(uuid, hash, ann)
+	}
+	return ann, hash, err
+}
+
+func (vc *VersionRecordingClient) SaveAnnotations(ctx context.Context, uuid string, hash string, data AnnotationsBody) (AnnotationsBody, string, error) {
+	ann, newHash, err := vc.AnnotationsClient.SaveAnnotations(ctx, uuid, hash, data)
+	if err == nil {
+		vc.record(uuid, newHash, ann)
+	}
+	return ann, newHash, err
+}
+
+// GetVersion returns the annotations of the content with the given Document-Hash, if they have been read or written recently
+func (vc *VersionRecordingClient) GetVersion(_ context.Context, uuid string, hash string) (AnnotationsBody, error) {
+	vc.mutex.Lock()
+	defer vc.mutex.Unlock()
+
+	ann, ok := vc.versions[versionKey(uuid, hash)]
+	if !ok {
+		return AnnotationsBody{}, ErrVersionNotFound
+	}
+	return ann, nil
+}
+
+func (vc *VersionRecordingClient) record(uuid string, hash string, ann AnnotationsBody) {
+	if hash == "" || vc.size < 1 {
+		return
+	}
+
+	vc.mutex.Lock()
+	defer vc.mutex.Unlock()
+
+	key := versionKey(uuid, hash)
+	if _, ok := vc.versions[key]; !ok {
+		vc.order = append(vc.order, key)
+	}
+	vc.versions[key] = ann
+
+	for len(vc.order) > vc.size {
+		delete(vc.versions, vc.order[0])
+		vc.order = vc.order[1:]
+	}
+}
+
+func versionKey(uuid string, hash string) string {
+	return uuid + "|" + hash
+}
+
+// mergeAnnotations applies the changes between base and submitted to current, treating annotations with the same predicate and concept as the same annotation.
+// An annotation changed or removed on one side and changed differently on the other is a conflict, in which case the merged annotations must not be used.
+func mergeAnnotations(base AnnotationsBody, current AnnotationsBody, submitted AnnotationsBody) (AnnotationsBody, []MergeConflict) {
+	baseByKey := annotationsByKey(base)
+	currentByKey := annotationsByKey(current)
+	submittedByKey := annotationsByKey(submitted)
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, body := range []AnnotationsBody{current, submitted} {
+		for _, ann := range body.Annotations {
+			if key := annotationKey(ann); !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	for _, ann := range base.Annotations {
+		if key := annotationKey(ann); !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	merged := AnnotationsBody{Annotations: []Annotation{}}
+	var conflicts []MergeConflict
+	for _, key := range keys {
+		b, inBase := baseByKey[key]
+		c, inCurrent := currentByKey[key]
+		s, inSubmitted := submittedByKey[key]
+
+		currentChanged := inCurrent != inBase || (inBase && annotationChanged(b, c))
+		submittedChanged := inSubmitted != inBase || (inBase && annotationChanged(b, s))
+
+		switch {
+		case !submittedChanged:
+			if inCurrent {
+				merged.Annotations = append(merged.Annotations, c)
+			}
+		case !currentChanged:
+			if inSubmitted {
+				merged.Annotations = append(merged.Annotations, s)
+			}
+		case inCurrent && inSubmitted && !annotationChanged(c, s):
+			// both sides made the same change
+			merged.Annotations = append(merged.Annotations, s)
+		case !inCurrent && !inSubmitted:
+			// both sides removed the annotation
+		default:
+			conflict := MergeConflict{Predicate: s.Predicate, ConceptID: s.ConceptID}
+			if inSubmitted {
+				conflict.Submitted = &s
+			}
+			if inCurrent {
+				conflict.Current = &c
+				conflict.Predicate, conflict.ConceptID = c.Predicate, c.ConceptID
+			}
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].Predicate+conflicts[i].ConceptID < conflicts[j].Predicate+conflicts[j].ConceptID
+	})
+	return merged, conflicts
+}
+
+func annotationsByKey(body AnnotationsBody) map[string]Annotation {
+	byKey := make(map[string]Annotation, len(body.Annotations))
+	for _, ann := range body.Annotations {
+		byKey[annotationKey(ann)] = ann
+	}
+	return byKey
+}
+
+func annotationKey(ann Annotation) string {
+	return ann.Predicate + "|" + ann.ConceptID
+}
+
+// annotationChanged reports whether the properties of two annotations with the same key differ.
+// A property missing on either side is not a change, since the r/w services enrich annotations with them.
+func annotationChanged(a Annotation, b Annotation) bool {
+	for _, p := range [][2]string{{a.Type, b.Type}, {a.APIURL, b.APIURL}, {a.PrefLabel, b.PrefLabel}} {
+		if p[0] != "" && p[1] != "" && p[0] != p[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package annotations
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeAnnotations(t *testing.T) {
+	about := Annotation{Predicate: "about", ConceptID: "a"}
+	mentions := Annotation{Predicate: "mentions", ConceptID: "b"}
+	author := Annotation{Predicate: "hasAuthor", ConceptID: "c"}
+	brand := Annotation{Predicate: "isClassifiedBy", ConceptID: "d"}
+	person := Annotation{Predicate: "mentions", ConceptID: "b", Type: "PERSON"}
+	organisation := Annotation{Predicate: "mentions", ConceptID: "b", Type: "ORGANISATION"}
+
+	tests := map[string]struct {
+		base      []Annotation
+		current   []Annotation
+		submitted []Annotation
+		expected  []Annotation
+		conflicts []MergeConflict
+	}{
+		"both add different annotations": {
+			base:      []Annotation{about},
+			current:   []Annotation{about, mentions},
+			submitted: []Annotation{about, author},
+			expected:  []Annotation{about, mentions, author},
+		},
+		"submitted removes an annotation": {
+			base:      []Annotation{about, mentions},
+			current:   []Annotation{about, mentions, brand},
+			submitted: []Annotation{about},
+			expected:  []Annotation{about, brand},
+		},
+		"current removes an annotation": {
+			base:      []Annotation{about, mentions},
+			current:   []Annotation{about},
+			submitted: []Annotation{about, mentions, author},
+			expected:  []Annotation{about, author},
+		},
+		"both remove the same annotation": {
+			base:      []Annotation{about, mentions},
+			current:   []Annotation{about},
+			submitted: []Annotation{about},
+			expected:  []Annotation{about},
+		},
+		"both add the same annotation": {
+			base:      []Annotation{about},
+			current:   []Annotation{about, mentions},
+			submitted: []Annotation{mentions, about},
+			expected:  []Annotation{about, mentions},
+		},
+		"enrichment is not a change": {
+			base:      []Annotation{mentions},
+			current:   []Annotation{person},
+			submitted: []Annotation{mentions, about},
+			expected:  []Annotation{person, about},
+		},
+		"both change the same annotation differently": {
+			base:      []Annotation{about, person},
+			current:   []Annotation{about, organisation},
+			submitted: []Annotation{about, {Predicate: "mentions", ConceptID: "b", Type: "BRAND"}},
+			conflicts: []MergeConflict{{Predicate: "mentions", ConceptID: "b", Current: &organisation, Submitted: &Annotation{Predicate: "mentions", ConceptID: "b", Type: "BRAND"}}},
+		},
+		"submitted removes an annotation changed by current": {
+			base:      []Annotation{about, person},
+			current:   []Annotation{about, organisation},
+			submitted: []Annotation{about},
+			conflicts: []MergeConflict{{Predicate: "mentions", ConceptID: "b", Current: &organisation}},
+		},
+		"both add the same annotation differently": {
+			base:      []Annotation{about},
+			current:   []Annotation{about, organisation},
+			submitted: []Annotation{about, person},
+			conflicts: []MergeConflict{{Predicate: "mentions", ConceptID: "b", Current: &organisation, Submitted: &person}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			merged, conflicts := mergeAnnotations(AnnotationsBody{Annotations: test.base}, AnnotationsBody{Annotations: test.current}, AnnotationsBody{Annotations: test.submitted})
+
+			assert.Equal(t, test.conflicts, conflicts)
+			if len(test.conflicts) == 0 {
+				assert.ElementsMatch(t, test.expected, merged.Annotations)
+			}
+		})
+	}
+}
+
+func TestVersionRecordingClient(t *testing.T) {
+	v1 := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	v2 := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "baz"}}}
+	v3 := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "qux"}}}
+
+	client := &mockAnnotationsClient{}
+	client.On("GetAnnotations", mock.Anything, "uuid").Return(v1, "hash1", nil)
+	client.On("SaveAnnotations", mock.Anything, "uuid", "hash1", v2).Return(v2, "hash2", nil)
+	client.On("SaveAnnotations", mock.Anything, "uuid", "hash2", v3).Return(v3, "hash3", nil)
+	client.On("SaveAnnotations", mock.Anything, "uuid", "hash3", v1).Return(AnnotationsBody{}, "", errors.New("eek"))
+
+	versions := NewVersionRecordingClient(client, 2)
+	ctx := context.Background()
+
+	_, _, err := versions.GetAnnotations(ctx, "uuid")
+	require.NoError(t, err)
+	actual, err := versions.GetVersion(ctx, "uuid", "hash1")
+	require.NoError(t, err)
+	assert.Equal(t, v1, actual)
+
+	_, _, err = versions.SaveAnnotations(ctx, "uuid", "hash1", v2)
+	require.NoError(t, err)
+	_, _, err = versions.SaveAnnotations(ctx, "uuid", "hash2", v3)
+	require.NoError(t, err)
+	_, _, err = versions.SaveAnnotations(ctx, "uuid", "hash3", v1)
+	require.Error(t, err)
+
+	_, err = versions.GetVersion(ctx, "uuid", "hash1")
+	assert.Equal(t, ErrVersionNotFound, err, "the oldest version should have been dropped")
+	actual, err = versions.GetVersion(ctx, "uuid", "hash3")
+	require.NoError(t, err)
+	assert.Equal(t, v3, actual)
+	_, err = versions.GetVersion(ctx, "other-uuid", "hash3")
+	assert.Equal(t, ErrVersionNotFound, err)
+
+	client.AssertExpectations(t)
+}
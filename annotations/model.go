@@ -1,7 +1,19 @@
 package annotations
 
+import "time"
+
 type AnnotationsBody struct {
 	Annotations []Annotation `json:"annotations"`
+	// Provenance is nil unless the annotations were saved or published by a known editor
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// Provenance records who last changed a set of annotations, and who published it, and when
+type Provenance struct {
+	LastModifiedBy string     `json:"lastModifiedBy,omitempty"`
+	LastModified   *time.Time `json:"lastModified,omitempty"`
+	PublishedBy    string     `json:"publishedBy,omitempty"`
+	Published      *time.Time `json:"published,omitempty"`
 }
 
 type Annotation struct {
@@ -12,3 +24,23 @@ type Annotation struct {
 	PrefLabel  string `json:"prefLabel,omitempty"`
 	IsFTAuthor bool   `json:"isFTAuthor,omitempty"`
 }
+
+// sameAnnotations reports whether both bodies hold the same set of predicate and concept pairs, ignoring order and enrichment such as prefLabel
+func sameAnnotations(a AnnotationsBody, b AnnotationsBody) bool {
+	if len(a.Annotations) != len(b.Annotations) {
+		return false
+	}
+
+	keys := make(map[string]int, len(a.Annotations))
+	for _, ann := range a.Annotations {
+		keys[ann.Predicate+"|"+ann.ConceptID]++
+	}
+	for _, ann := range b.Annotations {
+		key := ann.Predicate + "|" + ann.ConceptID
+		if keys[key] == 0 {
+			return false
+		}
+		keys[key]--
+	}
+	return true
+}
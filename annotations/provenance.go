@@ -0,0 +1,62 @@
+package annotations
+
+import (
+	"context"
+	"time"
+)
+
+type editorKey struct{}
+
+// WithEditor returns a copy of ctx holding the identity of the user who made the publish, which is recorded in the provenance of the annotations
+func WithEditor(ctx context.Context, editor string) context.Context {
+	if editor == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, editorKey{}, editor)
+}
+
+// EditorFromContext returns the identity of the user who made the publish, or an empty string if it is not known
+func EditorFromContext(ctx context.Context) string {
+	editor, _ := ctx.Value(editorKey{}).(string)
+	return editor
+}
+
+// modifiedBy returns the body with the editor of ctx as its last modifier. Provenance sent by the caller is never kept,
+// so the body has none if the editor is not known.
+func modifiedBy(ctx context.Context, body AnnotationsBody, at time.Time) AnnotationsBody {
+	body.Provenance = nil
+	if editor := EditorFromContext(ctx); editor != "" {
+		at = at.UTC()
+		body.Provenance = &Provenance{LastModifiedBy: editor, LastModified: &at}
+	}
+	return body
+}
+
+// publishedBy returns the body with the editor of ctx as its publisher, keeping its last modifier. The body is unchanged if the editor is not known.
+func publishedBy(ctx context.Context, body AnnotationsBody, at time.Time) AnnotationsBody {
+	editor := EditorFromContext(ctx)
+	if editor == "" {
+		return body
+	}
+
+	var provenance Provenance
+	if body.Provenance != nil {
+		provenance = *body.Provenance
+	}
+	at = at.UTC()
+	provenance.PublishedBy = editor
+	provenance.Published = &at
+	body.Provenance = &provenance
+	return body
+}
+
+// publishBody returns the body of the UPP publish of the annotations, which holds their provenance if they have any
+func publishBody(body AnnotationsBody) map[string]interface{} {
+	uppBody := map[string]interface{}{
+		"annotations": body.Annotations,
+	}
+	if body.Provenance != nil {
+		uppBody["provenance"] = body.Provenance
+	}
+	return uppBody
+}
@@ -9,27 +9,200 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/Financial-Times/annotations-publisher/breaker"
 	"github.com/Financial-Times/annotations-publisher/health"
+	"github.com/Financial-Times/annotations-publisher/tracing"
 	"github.com/Financial-Times/go-logger/v2"
 	tid "github.com/Financial-Times/transactionid-utils-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/Financial-Times/annotations-publisher/annotations")
+
 var (
 	// ErrInvalidAuthentication occurs when UPP responds with a 401
 	ErrInvalidAuthentication = errors.New("publish authentication is invalid")
 	ErrDraftNotFound         = errors.New("draft was not found")
 	ErrServiceTimeout        = errors.New("downstream service timed out")
+	// ErrNotPublished occurs when the published annotations of content which has never been published are requested
+	ErrNotPublished = errors.New("published annotations were not found")
+	// ErrConflict occurs when the r/w service rejects a write because the Previous-Document-Hash is not the current one
+	ErrConflict = errors.New("annotations have been changed since they were read")
+)
+
+// ConflictError occurs when the draft annotations were changed by someone else, and holds the current draft so the caller can merge and resubmit
+type ConflictError struct {
+	Cause error
+	// Current is nil if the current draft could not be read after the conflict
+	Current     *AnnotationsBody
+	CurrentHash string
+	// Conflicts are the annotations which could not be merged, if a merge was attempted
+	Conflicts []MergeConflict
+}
+
+func (e *ConflictError) Error() string {
+	return ErrConflict.Error()
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Cause
+}
+
+// RollbackOutcome describes what happened to the published annotations after a failed transactional publish
+type RollbackOutcome string
+
+const (
+	RollbackSucceeded RollbackOutcome = "succeeded"
+	RollbackFailed    RollbackOutcome = "failed"
+	// RollbackDeleted means there were no previously published annotations to restore, so the annotations which failed to publish were deleted
+	RollbackDeleted RollbackOutcome = "deleted"
 )
 
+// RollbackError occurs when the UPP publish of a transactional publish fails, and reports whether the previously published annotations were restored
+type RollbackError struct {
+	Cause       error
+	Outcome     RollbackOutcome
+	RollbackErr error
+}
+
+func (e *RollbackError) Error() string {
+	return e.Cause.Error()
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Cause
+}
+
 // Publisher provides an interface to publish annotations to UPP
 type Publisher interface {
 	health.ExternalService
 	Publish(ctx context.Context, uuid string, body map[string]interface{}) error
-	PublishFromStore(ctx context.Context, uuid string) error
-	SaveAndPublish(ctx context.Context, uuid string, hash string, body AnnotationsBody) error
+	PublishFromStore(ctx context.Context, uuid string) (string, error)
+	SaveAndPublish(ctx context.Context, uuid string, hash string, body AnnotationsBody) (string, error)
+	MergeAndPublish(ctx context.Context, uuid string, hash string, body AnnotationsBody) (string, error)
+	UnpublishedChanges(ctx context.Context, uuid string) (AnnotationsDiff, string, error)
+	PreviewPublish(ctx context.Context, uuid string, hash string, body *AnnotationsBody, merge bool) (map[string]interface{}, error)
+	Unpublish(ctx context.Context, uuid string) error
+	Republish(ctx context.Context, uuid string) (string, error)
+}
+
+// FailedPublishQueue records the UPP publishes which failed after the published annotations had been saved, so they can be replayed later
+type FailedPublishQueue interface {
+	// Add records the failed publish, replacing any failed publish of the same content
+	Add(ctx context.Context, uuid string, body map[string]interface{}, cause error) error
+	// Resolve forgets the failed publish of the content, once a later publish to UPP has succeeded
+	Resolve(ctx context.Context, uuid string) error
+}
+
+// PublishAttempt describes a publish made by the Publisher, whether it succeeded or not
+type PublishAttempt struct {
+	UUID           string
+	OriginSystemID string
+	// Editor identifies the user who made the publish, and is empty if they are not known
+	Editor    string
+	FromStore bool
+	Unpublish bool
+	Republish bool
+	// PreviousHash is the Previous-Document-Hash of a save and publish, or the hash of the draft annotations read by a publish from store
+	PreviousHash string
+	NewHash      string
+	// Previous are the annotations which were published before the attempt, nil if there were none or they could not be read
+	Previous *AnnotationsBody
+	// Published are the annotations written to the published store, nil if the attempt failed before writing them
+	Published *AnnotationsBody
+	Err       error
+	Latency   time.Duration
+}
+
+// PublishAuditor records every publish attempt made by the Publisher
+type PublishAuditor interface {
+	Record(ctx context.Context, attempt PublishAttempt) error
 }
 
+// Stage is a step of a publish made by the Publisher
+type Stage string
+
+const (
+	StageDraftRead     Stage = "draft_read"
+	StageDraftSave     Stage = "draft_save"
+	StagePublishedSave Stage = "published_save"
+	StageUPPPublish    Stage = "upp_publish"
+)
+
+// PublishObserver is told how long every stage of a publish took, and the outcome of every publish attempt
+type PublishObserver interface {
+	ObserveStage(stage Stage, duration time.Duration, err error)
+	ObservePublish(attempt PublishAttempt)
+}
+
+// PublisherOption configures optional behaviour of the Publisher
+type PublisherOption func(p *uppPublisher)
+
+// WithTransactionalPublish makes PublishFromStore restore the previously published annotations if the UPP publish fails,
+// so the published annotations store only ever holds what UPP has
+func WithTransactionalPublish() PublisherOption {
+	return func(p *uppPublisher) {
+		p.transactional = true
+	}
+}
+
+// WithFailedPublishQueue records UPP publishes which fail during PublishFromStore in the provided queue
+func WithFailedPublishQueue(queue FailedPublishQueue) PublisherOption {
+	return func(p *uppPublisher) {
+		p.failedPublishes = queue
+	}
+}
+
+// WithMerge enables three-way merges in MergeAndPublish, using the provided versions as the base of the merge
+func WithMerge(versions DraftVersions) PublisherOption {
+	return func(p *uppPublisher) {
+		p.versions = versions
+	}
+}
+
+// WithPublishAuditor records every PublishFromStore, SaveAndPublish and MergeAndPublish with the provided auditor.
+// The previously published annotations are read before they are overwritten, so the auditor can tell what changed.
+func WithPublishAuditor(auditor PublishAuditor) PublisherOption {
+	return func(p *uppPublisher) {
+		p.auditor = auditor
+	}
+}
+
+// WithValidation rejects annotations which fail any of the validators, before they are saved to the draft store by SaveAndPublish and MergeAndPublish,
+// and before the draft annotations are published by PublishFromStore
+func WithValidation(validators ...Validator) PublisherOption {
+	return func(p *uppPublisher) {
+		p.validators = append(p.validators, validators...)
+	}
+}
+
+// WithPublishLock makes PublishFromStore, SaveAndPublish and MergeAndPublish hold the lock of the content while they run,
+// so that concurrent publishes of the same content are applied one after the other
+func WithPublishLock(locker PublishLocker) PublisherOption {
+	return func(p *uppPublisher) {
+		p.locker = locker
+	}
+}
+
+// WithPublishObserver reports the duration of the reads and writes of the annotations and of the UPP publish,
+// and the outcome of every PublishFromStore, SaveAndPublish, MergeAndPublish, Unpublish and Republish, to the observer
+func WithPublishObserver(observer PublishObserver) PublisherOption {
+	return func(p *uppPublisher) {
+		p.observer = observer
+	}
+}
+
+// maxMergeAttempts is the number of times a merge is redone when the draft annotations are changed again while merging
+const maxMergeAttempts = 3
+
 type uppPublisher struct {
 	client                     *http.Client
 	originSystemID             string
@@ -38,20 +211,34 @@ type uppPublisher struct {
 	publishEndpoint            string
 	publishAuth                string
 	gtgEndpoint                string
+	failedPublishes            FailedPublishQueue
+	versions                   DraftVersions
+	auditor                    PublishAuditor
+	observer                   PublishObserver
+	validators                 []Validator
+	locker                     PublishLocker
+	transactional              bool
 	log                        *logger.UPPLogger
 }
 
 // NewPublisher returns a new Publisher instance
-func NewPublisher(originSystemID string, draftAnnotationsClient AnnotationsClient, publishedAnnotationsClient AnnotationsClient, publishEndpoint string, publishAuth string, gtgEndpoint string, client *http.Client, log *logger.UPPLogger) Publisher {
+func NewPublisher(originSystemID string, draftAnnotationsClient AnnotationsClient, publishedAnnotationsClient AnnotationsClient, publishEndpoint string, publishAuth string, gtgEndpoint string, client *http.Client, log *logger.UPPLogger, options ...PublisherOption) Publisher {
 	log.WithField("endpoint", draftAnnotationsClient.Endpoint()).Info("draft annotations r/w endpoint")
 	log.WithField("endpoint", publishedAnnotationsClient.Endpoint()).Info("published annotations r/w endpoint")
 	log.WithField("endpoint", publishEndpoint).Info("publish endpoint")
 
-	return &uppPublisher{client: client, originSystemID: originSystemID, draftAnnotationsClient: draftAnnotationsClient, publishedAnnotationsClient: publishedAnnotationsClient, publishEndpoint: publishEndpoint, publishAuth: publishAuth, gtgEndpoint: gtgEndpoint, log: log}
+	p := &uppPublisher{client: client, originSystemID: originSystemID, draftAnnotationsClient: draftAnnotationsClient, publishedAnnotationsClient: publishedAnnotationsClient, publishEndpoint: publishEndpoint, publishAuth: publishAuth, gtgEndpoint: gtgEndpoint, log: log}
+	for _, opt := range options {
+		opt(p)
+	}
+	return p
 }
 
 // Publish sends the annotations to UPP via the configured publishEndpoint. Requests contain X-Origin-System-Id and X-Request-Id and a User-Agent as provided.
-func (a *uppPublisher) Publish(ctx context.Context, uuid string, body map[string]interface{}) error {
+func (a *uppPublisher) Publish(ctx context.Context, uuid string, body map[string]interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, tracer, "Publish", uuid, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.String("url.full", a.publishEndpoint)))
+	defer func() { tracing.End(span, err) }()
+
 	txid, _ := tid.GetTransactionIDFromContext(ctx)
 	mlog := a.log.WithField("transaction_id", txid)
 
@@ -74,6 +261,9 @@ func (a *uppPublisher) Publish(ctx context.Context, uuid string, body map[string
 	req.Header.Add("X-Origin-System-Id", a.originSystemID)
 	req.Header.Add("Content-Type", "application/json")
 
+	start := time.Now()
+	defer func() { a.observeStage(StageUPPPublish, start, err) }()
+
 	resp, err := a.client.Do(req.WithContext(ctx))
 	if err != nil {
 		if isTimeoutErr(err) {
@@ -107,7 +297,8 @@ func (a *uppPublisher) addBasicAuth(r *http.Request) error {
 
 // GTG performs a health check against the UPP cms-metadata-notifier service
 func (a *uppPublisher) GTG() error {
-	req, err := http.NewRequest("GET", a.gtgEndpoint, nil)
+	// health checks do not count towards the circuit breaker of the service, which only tracks the requests made by publishes
+	req, err := http.NewRequestWithContext(breaker.Bypass(context.Background()), "GET", a.gtgEndpoint, nil)
 	if err != nil {
 		a.log.WithError(err).WithField("healthEndpoint", a.gtgEndpoint).Error("Error in creating GTG request for UPP cms-metadata-notifier service")
 		return err
@@ -141,7 +332,25 @@ func (a *uppPublisher) Endpoint() string {
 	return a.publishEndpoint
 }
 
-func (a *uppPublisher) PublishFromStore(ctx context.Context, uuid string) error {
+// PublishFromStore copies the current draft annotations to the published store and publishes them to UPP.
+// It returns the Document-Hash of the published annotations.
+func (a *uppPublisher) PublishFromStore(ctx context.Context, uuid string) (string, error) {
+	ctx, span := tracing.Start(ctx, tracer, "PublishFromStore", uuid)
+	start := time.Now()
+	attempt := &PublishAttempt{UUID: uuid, FromStore: true}
+
+	var hash string
+	unlock, err := a.lock(ctx, uuid)
+	if err == nil {
+		hash, err = a.publishFromStore(ctx, uuid, attempt)
+		unlock()
+	}
+	a.audit(ctx, attempt, start, hash, err)
+	tracing.End(span, err)
+	return hash, err
+}
+
+func (a *uppPublisher) publishFromStore(ctx context.Context, uuid string, attempt *PublishAttempt) (string, error) {
 	txid, _ := tid.GetTransactionIDFromContext(ctx)
 	mlog := a.log.WithField("transaction_id", txid)
 
@@ -150,41 +359,391 @@ func (a *uppPublisher) PublishFromStore(ctx context.Context, uuid string) error
 	var published AnnotationsBody
 	var err error
 
-	if draft, hash, err = a.draftAnnotationsClient.GetAnnotations(ctx, uuid); err == nil {
-		published, hash, err = a.draftAnnotationsClient.SaveAnnotations(ctx, uuid, hash, draft)
+	start := time.Now()
+	draft, hash, err = a.draftAnnotationsClient.GetAnnotations(ctx, uuid)
+	a.observeStage(StageDraftRead, start, err)
+	if err == nil {
+		if attempt.FromStore {
+			attempt.PreviousHash = hash
+		}
+		if err = a.validate(draft); err == nil {
+			start = time.Now()
+			published, hash, err = a.draftAnnotationsClient.SaveAnnotations(ctx, uuid, hash, draft)
+			a.observeStage(StageDraftSave, start, err)
+		}
 	}
 
 	if err != nil {
+		if errors.Is(err, ErrInvalidAnnotations) {
+			mlog.WithError(err).Warn("draft annotations are invalid")
+			return "", err
+		}
 		if isTimeoutErr(err) {
 			mlog.WithError(err).Error("r/w to draft annotations timed out ")
-			return ErrServiceTimeout
+			return "", ErrServiceTimeout
+		}
+		if errors.Is(err, ErrConflict) {
+			mlog.WithError(err).Warn("draft annotations were changed during publish")
+			return "", a.draftConflict(ctx, uuid, err)
 		}
 		mlog.WithError(err).Error("r/w to draft annotations failed")
-		return err
+		return "", err
+	}
+
+	var previous *AnnotationsBody
+	var previousHash string
+	if a.transactional {
+		previous, previousHash, err = a.getPublished(ctx, uuid)
+		if err != nil {
+			if isTimeoutErr(err) {
+				mlog.WithError(err).Error("published annotations read from PAC timed out ")
+				return "", ErrServiceTimeout
+			}
+			mlog.WithError(err).Error("read from published annotations failed")
+			return "", err
+		}
+	} else if a.auditor != nil {
+		// the previously published annotations are only needed to audit the changes, so failing to read them does not stop the publish
+		if previous, _, err = a.getPublished(ctx, uuid); err != nil {
+			mlog.WithError(err).Warn("failed to read previously published annotations for the publish history")
+		}
 	}
+	attempt.Previous = previous
 
+	start = time.Now()
+	published = publishedBy(ctx, published, start)
 	_, _, err = a.publishedAnnotationsClient.SaveAnnotations(ctx, uuid, hash, published)
+	a.observeStage(StagePublishedSave, start, err)
 	if err != nil {
 		if isTimeoutErr(err) {
 			mlog.WithError(err).Error("published annotations write to PAC timed out ")
-			return ErrServiceTimeout
+			return "", ErrServiceTimeout
 		}
 		mlog.WithError(err).Error("r/w to published annotations failed")
+		return "", err
+	}
+	attempt.Published = &published
+
+	uppPublishBody := publishBody(published)
+	if err = a.Publish(ctx, uuid, uppPublishBody); err != nil {
+		if !a.transactional {
+			a.recordFailedPublish(ctx, uuid, uppPublishBody, err)
+			return "", err
+		}
+
+		rollbackErr := a.rollback(ctx, uuid, previous, previousHash, err)
+		if rollbackErr.Outcome == RollbackFailed {
+			a.recordFailedPublish(ctx, uuid, uppPublishBody, err)
+		}
+		return "", rollbackErr
+	}
+
+	a.resolveFailedPublish(ctx, uuid)
+	return hash, nil
+}
+
+// Unpublish removes the annotations of the content from the published store and publishes an empty set of annotations to UPP, which removes them from UPP.
+// The draft annotations are kept, so the content can be published again. Content which has never been published is unpublished from UPP regardless.
+func (a *uppPublisher) Unpublish(ctx context.Context, uuid string) error {
+	start := time.Now()
+	attempt := &PublishAttempt{UUID: uuid, Unpublish: true}
+
+	unlock, err := a.lock(ctx, uuid)
+	if err == nil {
+		err = a.unpublish(ctx, uuid, attempt)
+		unlock()
+	}
+	a.audit(ctx, attempt, start, "", err)
+	return err
+}
+
+func (a *uppPublisher) unpublish(ctx context.Context, uuid string, attempt *PublishAttempt) error {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	mlog := a.log.WithField("transaction_id", txid).WithUUID(uuid)
+
+	if a.auditor != nil {
+		var err error
+		if attempt.Previous, _, err = a.getPublished(ctx, uuid); err != nil {
+			mlog.WithError(err).Warn("failed to read previously published annotations for the publish history")
+		}
+	}
+
+	err := a.publishedAnnotationsClient.DeleteAnnotations(ctx, uuid)
+	if err != nil && err != ErrDraftNotFound {
+		if isTimeoutErr(err) {
+			mlog.WithError(err).Error("published annotations delete from PAC timed out")
+			return ErrServiceTimeout
+		}
+		mlog.WithError(err).Error("delete from published annotations failed")
 		return err
 	}
+	attempt.Published = &AnnotationsBody{Annotations: []Annotation{}}
 
 	uppPublishBody := map[string]interface{}{
-		"annotations": published.Annotations,
+		"annotations": []Annotation{},
+	}
+	if err = a.Publish(ctx, uuid, uppPublishBody); err != nil {
+		mlog.WithError(err).Error("unpublish from upp failed")
+		a.recordFailedPublish(ctx, uuid, uppPublishBody, err)
+		return err
 	}
-	err = a.Publish(ctx, uuid, uppPublishBody)
 
-	return err
+	a.resolveFailedPublish(ctx, uuid)
+	mlog.Info("annotations have been unpublished")
+	return nil
+}
+
+// Republish publishes the annotations in the published store to UPP again, without reading or writing the draft annotations.
+// It returns the Document-Hash of the published annotations, or ErrNotPublished if the content has never been published.
+func (a *uppPublisher) Republish(ctx context.Context, uuid string) (string, error) {
+	start := time.Now()
+	attempt := &PublishAttempt{UUID: uuid, Republish: true}
+
+	var hash string
+	unlock, err := a.lock(ctx, uuid)
+	if err == nil {
+		hash, err = a.republish(ctx, uuid, attempt)
+		unlock()
+	}
+	a.audit(ctx, attempt, start, hash, err)
+	return hash, err
+}
+
+func (a *uppPublisher) republish(ctx context.Context, uuid string, attempt *PublishAttempt) (string, error) {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	mlog := a.log.WithField("transaction_id", txid).WithUUID(uuid)
+
+	published, hash, err := a.getPublished(ctx, uuid)
+	if err != nil {
+		if isTimeoutErr(err) {
+			mlog.WithError(err).Error("published annotations read from PAC timed out")
+			return "", ErrServiceTimeout
+		}
+		mlog.WithError(err).Error("read from published annotations failed")
+		return "", err
+	}
+	if published == nil {
+		return "", ErrNotPublished
+	}
+	attempt.PreviousHash = hash
+	attempt.Previous = published
+	attempt.Published = published
+
+	uppPublishBody := publishBody(*published)
+	if err = a.Publish(ctx, uuid, uppPublishBody); err != nil {
+		mlog.WithError(err).Error("republish to upp failed")
+		a.recordFailedPublish(ctx, uuid, uppPublishBody, err)
+		return "", err
+	}
+
+	a.resolveFailedPublish(ctx, uuid)
+	return hash, nil
 }
 
-func (a *uppPublisher) SaveAndPublish(ctx context.Context, uuid string, hash string, body AnnotationsBody) error {
+// UnpublishedChanges returns the changes the draft annotations make to the published annotations, and the Document-Hash of the draft annotations.
+// Every draft annotation is added if the content has never been published.
+func (a *uppPublisher) UnpublishedChanges(ctx context.Context, uuid string) (AnnotationsDiff, string, error) {
 	txid, _ := tid.GetTransactionIDFromContext(ctx)
 	mlog := a.log.WithField("transaction_id", txid)
-	_, _, err := a.draftAnnotationsClient.SaveAnnotations(ctx, uuid, hash, body)
+
+	draft, hash, err := a.draftAnnotationsClient.GetAnnotations(ctx, uuid)
+	if err != nil {
+		if isTimeoutErr(err) {
+			mlog.WithError(err).Error("read from draft annotations timed out")
+			return AnnotationsDiff{}, "", ErrServiceTimeout
+		}
+		mlog.WithError(err).Error("read from draft annotations failed")
+		return AnnotationsDiff{}, "", err
+	}
+
+	published, _, err := a.getPublished(ctx, uuid)
+	if err != nil {
+		if isTimeoutErr(err) {
+			mlog.WithError(err).Error("published annotations read from PAC timed out")
+			return AnnotationsDiff{}, "", ErrServiceTimeout
+		}
+		mlog.WithError(err).Error("read from published annotations failed")
+		return AnnotationsDiff{}, "", err
+	}
+
+	var from AnnotationsBody
+	if published != nil {
+		from = *published
+	}
+	return Diff(from, draft), hash, nil
+}
+
+// PreviewPublish makes the checks of SaveAndPublish, MergeAndPublish if merge is true, or PublishFromStore if body is nil,
+// without writing to either annotations store or publishing to UPP. It returns the body which would be published to UPP,
+// which holds the annotations as they were provided, since they are not saved to the draft annotations r/w service.
+func (a *uppPublisher) PreviewPublish(ctx context.Context, uuid string, hash string, body *AnnotationsBody, merge bool) (map[string]interface{}, error) {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	mlog := a.log.WithField("transaction_id", txid)
+
+	var published AnnotationsBody
+	var err error
+	if body == nil {
+		published, _, err = a.draftAnnotationsClient.GetAnnotations(ctx, uuid)
+	} else {
+		published, err = a.previewSave(ctx, uuid, hash, *body, merge)
+	}
+
+	if err == nil {
+		err = a.validate(published)
+	}
+
+	if err != nil {
+		if isTimeoutErr(err) {
+			mlog.WithError(err).Error("read from draft annotations timed out")
+			return nil, ErrServiceTimeout
+		}
+		if errors.Is(err, ErrInvalidAnnotations) {
+			mlog.WithError(err).Info("previewed publish has invalid annotations")
+			return nil, err
+		}
+		if errors.Is(err, ErrConflict) {
+			mlog.WithError(err).Info("previewed publish conflicts with the draft annotations")
+			return nil, err
+		}
+		mlog.WithError(err).Error("read from draft annotations failed")
+		return nil, err
+	}
+
+	now := time.Now()
+	if body != nil {
+		published = modifiedBy(ctx, published, now)
+	}
+	uppPublishBody := publishBody(publishedBy(ctx, published, now))
+	// Publish adds the uuid to the body it sends
+	uppPublishBody["uuid"] = uuid
+	return uppPublishBody, nil
+}
+
+// previewSave returns the annotations which would be saved to the draft store, or the ConflictError the save would cause
+func (a *uppPublisher) previewSave(ctx context.Context, uuid string, hash string, body AnnotationsBody, merge bool) (AnnotationsBody, error) {
+	// the r/w service accepts any write without a Previous-Document-Hash
+	if hash == "" {
+		return body, nil
+	}
+
+	current, currentHash, err := a.draftAnnotationsClient.GetAnnotations(ctx, uuid)
+	if err == ErrDraftNotFound {
+		return body, nil
+	}
+	if err != nil {
+		return AnnotationsBody{}, err
+	}
+	if currentHash == hash {
+		return body, nil
+	}
+
+	conflict := &ConflictError{Cause: ErrConflict, Current: &current, CurrentHash: currentHash}
+	if !merge || a.versions == nil {
+		return AnnotationsBody{}, conflict
+	}
+	base, err := a.versions.GetVersion(ctx, uuid, hash)
+	if err != nil {
+		return AnnotationsBody{}, conflict
+	}
+
+	merged, conflicts := mergeAnnotations(base, current, body)
+	if len(conflicts) > 0 {
+		conflict.Conflicts = conflicts
+		return AnnotationsBody{}, conflict
+	}
+	return merged, nil
+}
+
+// getPublished returns the currently published annotations, or nil if the content has never been published
+func (a *uppPublisher) getPublished(ctx context.Context, uuid string) (*AnnotationsBody, string, error) {
+	published, hash, err := a.publishedAnnotationsClient.GetAnnotations(ctx, uuid)
+	if err == ErrDraftNotFound {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &published, hash, nil
+}
+
+// rollback restores the previously published annotations after the UPP publish failed with cause,
+// or deletes the published annotations if the content had never been published
+func (a *uppPublisher) rollback(ctx context.Context, uuid string, previous *AnnotationsBody, previousHash string, cause error) *RollbackError {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	mlog := a.log.WithField("transaction_id", txid).WithUUID(uuid)
+
+	// the publish may have failed because the request deadline passed, which must not prevent the rollback
+	ctx = context.WithoutCancel(ctx)
+
+	if previous == nil {
+		if err := a.publishedAnnotationsClient.DeleteAnnotations(ctx, uuid); err != nil && err != ErrDraftNotFound {
+			mlog.WithError(err).Error("failed to delete published annotations after upp publish failed")
+			return &RollbackError{Cause: cause, Outcome: RollbackFailed, RollbackErr: err}
+		}
+		mlog.WithError(cause).Warn("upp publish failed, published annotations have been deleted as there were none before")
+		return &RollbackError{Cause: cause, Outcome: RollbackDeleted}
+	}
+
+	_, _, err := a.publishedAnnotationsClient.SaveAnnotations(ctx, uuid, previousHash, *previous)
+	if err != nil {
+		mlog.WithError(err).Error("failed to restore previously published annotations after upp publish failed")
+		return &RollbackError{Cause: cause, Outcome: RollbackFailed, RollbackErr: err}
+	}
+
+	mlog.WithError(cause).Warn("upp publish failed, previously published annotations have been restored")
+	return &RollbackError{Cause: cause, Outcome: RollbackSucceeded}
+}
+
+// SaveAndPublish writes the provided annotations to the draft store and then publishes them as PublishFromStore does.
+// It returns the Document-Hash of the published annotations.
+func (a *uppPublisher) SaveAndPublish(ctx context.Context, uuid string, hash string, body AnnotationsBody) (string, error) {
+	ctx, span := tracing.Start(ctx, tracer, "SaveAndPublish", uuid)
+	newHash, err := a.saveAndPublish(ctx, uuid, hash, body, false)
+	tracing.End(span, err)
+	return newHash, err
+}
+
+// MergeAndPublish saves and publishes the provided annotations as SaveAndPublish does, but if the draft annotations have been changed since the provided hash,
+// the changes made by the provided annotations to the version with that hash are merged into the current draft annotations.
+// It returns a ConflictError if the version with that hash is not known, or if both changed the same annotation.
+func (a *uppPublisher) MergeAndPublish(ctx context.Context, uuid string, hash string, body AnnotationsBody) (string, error) {
+	ctx, span := tracing.Start(ctx, tracer, "MergeAndPublish", uuid)
+	newHash, err := a.saveAndPublish(ctx, uuid, hash, body, true)
+	tracing.End(span, err)
+	return newHash, err
+}
+
+func (a *uppPublisher) saveAndPublish(ctx context.Context, uuid string, hash string, body AnnotationsBody, merge bool) (string, error) {
+	start := time.Now()
+	attempt := &PublishAttempt{UUID: uuid, PreviousHash: hash}
+
+	var newHash string
+	err := a.validate(body)
+	if err == nil {
+		var unlock func()
+		if unlock, err = a.lock(ctx, uuid); err == nil {
+			defer unlock()
+		}
+	}
+	if err == nil {
+		err = a.saveDraft(ctx, uuid, hash, body, merge)
+	}
+	if err == nil {
+		newHash, err = a.publishFromStore(ctx, uuid, attempt)
+	}
+	a.audit(ctx, attempt, start, newHash, err)
+	return newHash, err
+}
+
+func (a *uppPublisher) saveDraft(ctx context.Context, uuid string, hash string, body AnnotationsBody, merge bool) error {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	mlog := a.log.WithField("transaction_id", txid)
+	start := time.Now()
+	_, _, err := a.draftAnnotationsClient.SaveAnnotations(ctx, uuid, hash, modifiedBy(ctx, body, start))
+	a.observeStage(StageDraftSave, start, err)
+	if merge && errors.Is(err, ErrConflict) {
+		err = a.mergeDraft(ctx, uuid, hash, body, err)
+	}
 
 	if err != nil {
 		if isTimeoutErr(err) {
@@ -192,10 +751,154 @@ func (a *uppPublisher) SaveAndPublish(ctx context.Context, uuid string, hash str
 			return ErrServiceTimeout
 		}
 
+		var conflictErr *ConflictError
+		if errors.As(err, &conflictErr) {
+			mlog.WithError(err).WithField("conflicts", len(conflictErr.Conflicts)).Warn("draft annotations could not be merged")
+			return err
+		}
+		if errors.Is(err, ErrConflict) {
+			mlog.WithError(err).Warn("draft annotations have been changed since the provided hash")
+			return a.draftConflict(ctx, uuid, err)
+		}
 		mlog.WithError(err).Error("write to draft annotations failed")
 		return err
 	}
-	return a.PublishFromStore(ctx, uuid)
+	return nil
+}
+
+// mergeDraft merges the changes made by body to the version with baseHash into the current draft annotations and saves the result.
+// It returns cause if the version with baseHash is not known.
+func (a *uppPublisher) mergeDraft(ctx context.Context, uuid string, baseHash string, body AnnotationsBody, cause error) error {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	mlog := a.log.WithField("transaction_id", txid).WithUUID(uuid)
+
+	if a.versions == nil || baseHash == "" {
+		return cause
+	}
+	base, err := a.versions.GetVersion(ctx, uuid, baseHash)
+	if err != nil {
+		mlog.WithError(err).Warn("base version of the draft annotations is not available for a merge")
+		return cause
+	}
+
+	for attempt := 1; attempt <= maxMergeAttempts; attempt++ {
+		current, currentHash, err := a.draftAnnotationsClient.GetAnnotations(ctx, uuid)
+		if err != nil {
+			return err
+		}
+
+		merged, conflicts := mergeAnnotations(base, current, body)
+		if len(conflicts) > 0 {
+			return &ConflictError{Cause: cause, Current: &current, CurrentHash: currentHash, Conflicts: conflicts}
+		}
+
+		_, _, err = a.draftAnnotationsClient.SaveAnnotations(ctx, uuid, currentHash, modifiedBy(ctx, merged, time.Now()))
+		if !errors.Is(err, ErrConflict) {
+			if err == nil {
+				mlog.WithField("attempt", attempt).Info("merged draft annotations with concurrent changes")
+			}
+			return err
+		}
+		cause = err
+	}
+	return cause
+}
+
+// lock waits for the publish lock of the content, if there is a PublishLocker, and returns the function which releases it
+func (a *uppPublisher) lock(ctx context.Context, uuid string) (func(), error) {
+	if a.locker == nil {
+		return func() {}, nil
+	}
+
+	unlock, err := a.locker.Lock(ctx, uuid)
+	if err != nil {
+		txid, _ := tid.GetTransactionIDFromContext(ctx)
+		mlog := a.log.WithField("transaction_id", txid).WithUUID(uuid)
+		if ctx.Err() != nil {
+			mlog.WithError(err).Error("timed out waiting for another publish of the content")
+			return nil, ErrServiceTimeout
+		}
+		mlog.WithError(err).Error("failed to acquire publish lock")
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// validate checks the annotations with every validator, returning a ValidationError with the invalid fields reported by all of them
+func (a *uppPublisher) validate(body AnnotationsBody) error {
+	var fieldErrs []FieldError
+	for _, v := range a.validators {
+		fieldErrs = append(fieldErrs, v.Validate(body)...)
+	}
+	if len(fieldErrs) > 0 {
+		return &ValidationError{Errors: fieldErrs}
+	}
+	return nil
+}
+
+// draftConflict reads the current draft annotations after a conflicting write, so the caller can merge them with its changes
+func (a *uppPublisher) draftConflict(ctx context.Context, uuid string, cause error) *ConflictError {
+	current, hash, err := a.draftAnnotationsClient.GetAnnotations(ctx, uuid)
+	if err != nil {
+		txid, _ := tid.GetTransactionIDFromContext(ctx)
+		a.log.WithField("transaction_id", txid).WithUUID(uuid).WithError(err).Warn("failed to read current draft annotations after conflict")
+		return &ConflictError{Cause: cause}
+	}
+	return &ConflictError{Cause: cause, Current: &current, CurrentHash: hash}
+}
+
+// observeStage reports the duration of a stage of a publish to the PublishObserver, if there is one
+func (a *uppPublisher) observeStage(stage Stage, start time.Time, err error) {
+	if a.observer != nil {
+		a.observer.ObserveStage(stage, time.Since(start), err)
+	}
+}
+
+// audit reports the publish attempt to the PublishObserver and records it with the PublishAuditor, if there are any. Failing to record it does not fail the publish.
+func (a *uppPublisher) audit(ctx context.Context, attempt *PublishAttempt, start time.Time, hash string, err error) {
+	attempt.OriginSystemID = a.originSystemID
+	attempt.Editor = EditorFromContext(ctx)
+	attempt.NewHash = hash
+	attempt.Err = err
+	attempt.Latency = time.Since(start)
+	if a.observer != nil {
+		a.observer.ObservePublish(*attempt)
+	}
+	if a.auditor == nil {
+		return
+	}
+
+	if auditErr := a.auditor.Record(context.WithoutCancel(ctx), *attempt); auditErr != nil {
+		txid, _ := tid.GetTransactionIDFromContext(ctx)
+		a.log.WithField("transaction_id", txid).WithUUID(attempt.UUID).WithError(auditErr).Error("failed to record publish in the publish history")
+	}
+}
+
+// recordFailedPublish adds the UPP publish to the failed publish queue, since the published annotations store already holds the new version
+func (a *uppPublisher) recordFailedPublish(ctx context.Context, uuid string, body map[string]interface{}, cause error) {
+	if a.failedPublishes == nil {
+		return
+	}
+
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	mlog := a.log.WithField("transaction_id", txid).WithUUID(uuid)
+	if err := a.failedPublishes.Add(ctx, uuid, body, cause); err != nil {
+		mlog.WithError(err).Error("failed to record failed upp publish for retry, published annotations and UPP are out of sync")
+		return
+	}
+	mlog.WithError(cause).Warn("recorded failed upp publish for retry")
+}
+
+// resolveFailedPublish removes any failed publish of the content from the failed publish queue, so an older version of its annotations is never replayed to UPP
+func (a *uppPublisher) resolveFailedPublish(ctx context.Context, uuid string) {
+	if a.failedPublishes == nil {
+		return
+	}
+
+	if err := a.failedPublishes.Resolve(ctx, uuid); err != nil {
+		txid, _ := tid.GetTransactionIDFromContext(ctx)
+		a.log.WithField("transaction_id", txid).WithUUID(uuid).WithError(err).Error("failed to remove resolved upp publish from the retry queue, an older version may be replayed to UPP")
+	}
 }
 
 func isTimeoutErr(err error) bool {
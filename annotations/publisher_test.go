@@ -45,6 +45,11 @@ func (m *mockAnnotationsClient) SaveAnnotations(ctx context.Context, uuid string
 	return args.Get(0).(AnnotationsBody), args.String(1), args.Error(2)
 }
 
+func (m *mockAnnotationsClient) DeleteAnnotations(ctx context.Context, uuid string) error {
+	args := m.Called(ctx, uuid)
+	return args.Error(0)
+}
+
 func (m *mockAnnotationsClient) GTG() error {
 	args := m.Called()
 	return args.Error(0)
@@ -54,6 +59,54 @@ func (m *mockAnnotationsClient) Endpoint() string {
 	return "http://localhost"
 }
 
+type mockFailedPublishQueue struct {
+	mock.Mock
+}
+
+type mockDraftVersions struct {
+	mock.Mock
+}
+
+func (m *mockDraftVersions) GetVersion(ctx context.Context, uuid string, hash string) (AnnotationsBody, error) {
+	args := m.Called(ctx, uuid, hash)
+	return args.Get(0).(AnnotationsBody), args.Error(1)
+}
+
+type mockPublishAuditor struct {
+	mock.Mock
+}
+
+func (m *mockPublishAuditor) Record(ctx context.Context, attempt PublishAttempt) error {
+	args := m.Called(ctx, attempt)
+	return args.Error(0)
+}
+
+// recordingObserver keeps the stages and publishes it is told about
+type recordingObserver struct {
+	stages    []Stage
+	stageErrs []error
+	publishes []PublishAttempt
+}
+
+func (o *recordingObserver) ObserveStage(stage Stage, duration time.Duration, err error) {
+	o.stages = append(o.stages, stage)
+	o.stageErrs = append(o.stageErrs, err)
+}
+
+func (o *recordingObserver) ObservePublish(attempt PublishAttempt) {
+	o.publishes = append(o.publishes, attempt)
+}
+
+func (m *mockFailedPublishQueue) Add(ctx context.Context, uuid string, body map[string]interface{}, cause error) error {
+	args := m.Called(ctx, uuid, body, cause)
+	return args.Error(0)
+}
+
+func (m *mockFailedPublishQueue) Resolve(ctx context.Context, uuid string) error {
+	args := m.Called(ctx, uuid)
+	return args.Error(0)
+}
+
 func TestPublish(t *testing.T) {
 	uuid := uuid.New()
 	server := startMockServer(context.Background(), t, uuid, true, true, time.Duration(0))
@@ -284,7 +337,7 @@ func TestPublisherGTGInvalidURL(t *testing.T) {
 
 func TestPublishFromStore(t *testing.T) {
 	uuid := uuid.New()
-	testAnnotations := AnnotationsBody{[]Annotation{
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{
 		{
 			Predicate: "foo",
 			ConceptID: "bar",
@@ -313,8 +366,9 @@ func TestPublishFromStore(t *testing.T) {
 	require.NoError(t, err)
 	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
 
-	err = publisher.PublishFromStore(ctx, uuid)
+	hash, err := publisher.PublishFromStore(ctx, uuid)
 	assert.NoError(t, err)
+	assert.Equal(t, updatedHash, hash)
 
 	draftAnnotationsClient.AssertExpectations(t)
 	publishedAnnotationsClient.AssertExpectations(t)
@@ -334,7 +388,7 @@ func TestPublishFromStoreNotFound(t *testing.T) {
 
 	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
 	defer cancel()
-	err = publisher.PublishFromStore(ctx, uuid)
+	_, err = publisher.PublishFromStore(ctx, uuid)
 	assert.EqualError(t, err, ErrDraftNotFound.Error())
 
 	draftAnnotationsClient.AssertExpectations(t)
@@ -355,7 +409,7 @@ func TestPublishFromStoreDraftAnnotationsGetTimeOut(t *testing.T) {
 
 	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
 	defer cancel()
-	err = publisher.PublishFromStore(ctx, uuid)
+	_, err = publisher.PublishFromStore(ctx, uuid)
 	assert.EqualError(t, err, ErrServiceTimeout.Error())
 
 	draftAnnotationsClient.AssertExpectations(t)
@@ -377,7 +431,7 @@ func TestPublishFromStoreGetDraftsFails(t *testing.T) {
 
 	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
 	defer cancel()
-	err = publisher.PublishFromStore(ctx, uuid)
+	_, err = publisher.PublishFromStore(ctx, uuid)
 	assert.EqualError(t, err, msg)
 
 	draftAnnotationsClient.AssertExpectations(t)
@@ -387,7 +441,7 @@ func TestPublishFromStoreGetDraftsFails(t *testing.T) {
 func TestPublishFromStoreSaveDraftFails(t *testing.T) {
 	msg := "test error"
 	uuid := uuid.New()
-	testAnnotations := AnnotationsBody{[]Annotation{
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{
 		{
 			Predicate: "foo",
 			ConceptID: "bar",
@@ -414,7 +468,7 @@ func TestPublishFromStoreSaveDraftFails(t *testing.T) {
 	require.NoError(t, err)
 	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
 
-	err = publisher.PublishFromStore(ctx, uuid)
+	_, err = publisher.PublishFromStore(ctx, uuid)
 	assert.EqualError(t, err, msg)
 
 	draftAnnotationsClient.AssertExpectations(t)
@@ -423,7 +477,7 @@ func TestPublishFromStoreSaveDraftFails(t *testing.T) {
 
 func TestPublishFromStoreSaveDraftTimeout(t *testing.T) {
 	uuid := uuid.New()
-	testAnnotations := AnnotationsBody{[]Annotation{
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{
 		{
 			Predicate: "foo",
 			ConceptID: "bar",
@@ -450,7 +504,7 @@ func TestPublishFromStoreSaveDraftTimeout(t *testing.T) {
 	require.NoError(t, err)
 	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
 
-	err = publisher.PublishFromStore(ctx, uuid)
+	_, err = publisher.PublishFromStore(ctx, uuid)
 	assert.EqualError(t, err, ErrServiceTimeout.Error())
 
 	draftAnnotationsClient.AssertExpectations(t)
@@ -460,7 +514,7 @@ func TestPublishFromStoreSaveDraftTimeout(t *testing.T) {
 func TestPublishFromStoreSavePublishedFails(t *testing.T) {
 	msg := "test error"
 	uuid := uuid.New()
-	testAnnotations := AnnotationsBody{[]Annotation{
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{
 		{
 			Predicate: "foo",
 			ConceptID: "bar",
@@ -488,7 +542,7 @@ func TestPublishFromStoreSavePublishedFails(t *testing.T) {
 	require.NoError(t, err)
 	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
 
-	err = publisher.PublishFromStore(ctx, uuid)
+	_, err = publisher.PublishFromStore(ctx, uuid)
 	assert.EqualError(t, err, msg)
 
 	draftAnnotationsClient.AssertExpectations(t)
@@ -497,7 +551,7 @@ func TestPublishFromStoreSavePublishedFails(t *testing.T) {
 
 func TestPublishFromStoreSavePublishedTimeout(t *testing.T) {
 	uuid := uuid.New()
-	testAnnotations := AnnotationsBody{[]Annotation{
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{
 		{
 			Predicate: "foo",
 			ConceptID: "bar",
@@ -525,7 +579,7 @@ func TestPublishFromStoreSavePublishedTimeout(t *testing.T) {
 	require.NoError(t, err)
 	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
 
-	err = publisher.PublishFromStore(ctx, uuid)
+	_, err = publisher.PublishFromStore(ctx, uuid)
 	assert.EqualError(t, err, ErrServiceTimeout.Error())
 
 	draftAnnotationsClient.AssertExpectations(t)
@@ -534,7 +588,7 @@ func TestPublishFromStoreSavePublishedTimeout(t *testing.T) {
 
 func TestPublishFromStorePublishFails(t *testing.T) {
 	uuid := uuid.New()
-	testAnnotations := AnnotationsBody{[]Annotation{
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{
 		{
 			Predicate: "foo",
 			ConceptID: "bar",
@@ -560,16 +614,174 @@ func TestPublishFromStorePublishFails(t *testing.T) {
 	require.NoError(t, err)
 	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
 
-	err = publisher.PublishFromStore(ctx, uuid)
+	_, err = publisher.PublishFromStore(ctx, uuid)
+	assert.EqualError(t, err, fmt.Sprintf("publish to %v/notify returned a 503 status code", server.URL))
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestPublishFromStorePublishFailsIsQueuedForRetry(t *testing.T) {
+	uuid := uuid.New()
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{
+		{
+			Predicate: "foo",
+			ConceptID: "bar",
+		},
+	},
+	}
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(testAnnotations, "", nil)
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "", testAnnotations).Return(testAnnotations, "", nil)
+
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "", testAnnotations).Return(testAnnotations, "", nil)
+
+	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
+	defer cancel()
+	server := startMockServer(ctx, t, uuid, false, true, time.Duration(0))
+	defer server.Close()
+
+	failedPublishes := &mockFailedPublishQueue{}
+	failedPublishes.On("Add", mock.MatchedBy(func(ctx context.Context) bool {
+		txid, err := tid.GetTransactionIDFromContext(ctx)
+		return err == nil && txid == "tid_test"
+	}), uuid, map[string]interface{}{"uuid": uuid, "annotations": testAnnotations.Annotations}, mock.Anything).Return(nil)
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithFailedPublishQueue(failedPublishes))
+
+	_, err = publisher.PublishFromStore(ctx, uuid)
 	assert.EqualError(t, err, fmt.Sprintf("publish to %v/notify returned a 503 status code", server.URL))
 
 	draftAnnotationsClient.AssertExpectations(t)
 	publishedAnnotationsClient.AssertExpectations(t)
+	failedPublishes.AssertExpectations(t)
+}
+
+func TestTransactionalPublishFromStore(t *testing.T) {
+	uuid := uuid.New()
+	draftAnnotations := AnnotationsBody{Annotations: []Annotation{
+		{
+			Predicate: "foo",
+			ConceptID: "bar",
+		},
+	},
+	}
+	previousAnnotations := AnnotationsBody{Annotations: []Annotation{
+		{
+			Predicate: "foo",
+			ConceptID: "baz",
+		},
+	},
+	}
+
+	tests := map[string]struct {
+		publishOk        bool
+		previous         AnnotationsBody
+		previousErr      error
+		restoreErr       error
+		deleteErr        error
+		expectedOutcome  RollbackOutcome
+		expectedRestore  bool
+		expectedDelete   bool
+		expectedRecorded bool
+	}{
+		"publish succeeds": {
+			publishOk: true,
+			previous:  previousAnnotations,
+		},
+		"rollback succeeds": {
+			previous:        previousAnnotations,
+			expectedOutcome: RollbackSucceeded,
+			expectedRestore: true,
+		},
+		"rollback fails": {
+			previous:         previousAnnotations,
+			restoreErr:       errors.New("eek"),
+			expectedOutcome:  RollbackFailed,
+			expectedRestore:  true,
+			expectedRecorded: true,
+		},
+		"never published": {
+			previousErr:     ErrDraftNotFound,
+			expectedOutcome: RollbackDeleted,
+			expectedDelete:  true,
+		},
+		"delete fails": {
+			previousErr:      ErrDraftNotFound,
+			deleteErr:        errors.New("eek"),
+			expectedOutcome:  RollbackFailed,
+			expectedDelete:   true,
+			expectedRecorded: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			draftAnnotationsClient := &mockAnnotationsClient{}
+			draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(draftAnnotations, "hash", nil)
+			draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "hash", draftAnnotations).Return(draftAnnotations, "newhash", nil)
+
+			publishedAnnotationsClient := &mockAnnotationsClient{}
+			publishedAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(test.previous, "previoushash", test.previousErr)
+			publishedAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "newhash", draftAnnotations).Return(draftAnnotations, "newhash", nil)
+			if test.expectedRestore {
+				publishedAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "previoushash", test.previous).Return(test.previous, "previoushash", test.restoreErr)
+			}
+			if test.expectedDelete {
+				publishedAnnotationsClient.On("DeleteAnnotations", mock.Anything, uuid).Return(test.deleteErr)
+			}
+
+			failedPublishes := &mockFailedPublishQueue{}
+			if test.expectedRecorded {
+				failedPublishes.On("Add", mock.Anything, uuid, mock.Anything, mock.Anything).Return(nil)
+			}
+			if test.publishOk {
+				failedPublishes.On("Resolve", mock.Anything, uuid).Return(nil)
+			}
+
+			ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
+			defer cancel()
+			server := startMockServer(ctx, t, uuid, test.publishOk, true, time.Duration(0))
+			defer server.Close()
+
+			testingClient, err := fthttp.NewClient(
+				fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+			)
+			require.NoError(t, err)
+			publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithTransactionalPublish(), WithFailedPublishQueue(failedPublishes))
+
+			hash, err := publisher.PublishFromStore(ctx, uuid)
+			if test.publishOk {
+				assert.NoError(t, err)
+				assert.Equal(t, "newhash", hash)
+			} else {
+				var rollbackErr *RollbackError
+				require.True(t, errors.As(err, &rollbackErr))
+				assert.Equal(t, test.expectedOutcome, rollbackErr.Outcome)
+				if test.expectedDelete {
+					assert.Equal(t, test.deleteErr, rollbackErr.RollbackErr)
+				} else {
+					assert.Equal(t, test.restoreErr, rollbackErr.RollbackErr)
+				}
+				assert.EqualError(t, err, fmt.Sprintf("publish to %v/notify returned a 503 status code", server.URL))
+			}
+
+			draftAnnotationsClient.AssertExpectations(t)
+			publishedAnnotationsClient.AssertExpectations(t)
+			failedPublishes.AssertExpectations(t)
+		})
+	}
 }
 
 func TestSaveAndPublish(t *testing.T) {
 	uuid := uuid.New()
-	testAnnotations := AnnotationsBody{[]Annotation{
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{
 		{
 			Predicate: "foo",
 			ConceptID: "bar",
@@ -598,8 +810,121 @@ func TestSaveAndPublish(t *testing.T) {
 	require.NoError(t, err)
 	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
 
-	err = publisher.SaveAndPublish(ctx, uuid, testHash, testAnnotations)
+	hash, err := publisher.SaveAndPublish(ctx, uuid, testHash, testAnnotations)
 	assert.NoError(t, err)
+	assert.Equal(t, updatedHash, hash)
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestSaveAndPublishRecordsProvenance(t *testing.T) {
+	uuid := uuid.New()
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	ctx := WithEditor(tid.TransactionAwareContext(context.Background(), "tid_test"), "an-editor")
+	// provenance sent by the caller is replaced
+	submitted := testAnnotations
+	submitted.Provenance = &Provenance{LastModifiedBy: "someone-else", PublishedBy: "someone-else"}
+
+	modified := time.Now().UTC()
+	draft := AnnotationsBody{Annotations: testAnnotations.Annotations, Provenance: &Provenance{LastModifiedBy: "an-editor", LastModified: &modified}}
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "hash", mock.MatchedBy(func(body AnnotationsBody) bool {
+		return body.Provenance != nil && body.Provenance.LastModifiedBy == "an-editor" && body.Provenance.LastModified != nil && body.Provenance.PublishedBy == ""
+	})).Return(testAnnotations, "newhash", nil)
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(draft, "newhash", nil)
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "newhash", draft).Return(draft, "newhash", nil)
+
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "newhash", mock.MatchedBy(func(body AnnotationsBody) bool {
+		return body.Provenance != nil && body.Provenance.LastModifiedBy == "an-editor" && body.Provenance.PublishedBy == "an-editor" && body.Provenance.Published != nil
+	})).Return(testAnnotations, "newhash", nil)
+
+	var uppBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&uppBody))
+	}))
+	defer server.Close()
+
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL, "user:pass", "http://www.example.com/__gtg", http.DefaultClient, logger.NewUPPLogger("test", "DEBUG"))
+
+	_, err := publisher.SaveAndPublish(ctx, uuid, "hash", submitted)
+	require.NoError(t, err)
+
+	require.Contains(t, uppBody, "provenance")
+	provenance := uppBody["provenance"].(map[string]interface{})
+	assert.Equal(t, "an-editor", provenance["lastModifiedBy"])
+	assert.Equal(t, "an-editor", provenance["publishedBy"])
+	assert.NotEmpty(t, provenance["published"])
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestSaveAndPublishInvalidAnnotations(t *testing.T) {
+	uuid := uuid.New()
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithValidation(NewSchemaValidator([]string{"foo"}, nil)))
+
+	_, err = publisher.SaveAndPublish(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid, "hash", testAnnotations)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []FieldError{{Field: "annotations[0].id", Value: "bar", Message: "is not a concept URI ending in a UUID"}}, validationErr.Errors)
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestPublishFromStoreInvalidAnnotations(t *testing.T) {
+	uuid := uuid.New()
+	draft := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd"}}}
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(draft, "hash", nil)
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithValidation(NewSchemaValidator([]string{"about"}, nil)))
+
+	_, err = publisher.PublishFromStore(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid)
+	assert.True(t, errors.Is(err, ErrInvalidAnnotations))
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestSaveAndPublishWaitsForPublishLock(t *testing.T) {
+	uuid := uuid.New()
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	locker := NewKeyedLocker()
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithPublishLock(locker))
+
+	unlock, err := locker.Lock(context.Background(), uuid)
+	require.NoError(t, err)
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 20*time.Millisecond)
+	defer cancel()
+	_, err = publisher.SaveAndPublish(ctx, uuid, "hash", testAnnotations)
+	assert.Equal(t, ErrServiceTimeout, err)
 
 	draftAnnotationsClient.AssertExpectations(t)
 	publishedAnnotationsClient.AssertExpectations(t)
@@ -608,7 +933,7 @@ func TestSaveAndPublish(t *testing.T) {
 func TestSaveAndPublishNotFound(t *testing.T) {
 	uuid := uuid.New()
 	testHash := "hashhashhashhash"
-	testAnnotations := AnnotationsBody{[]Annotation{
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{
 		{
 			Predicate: "foo",
 			ConceptID: "bar",
@@ -627,7 +952,7 @@ func TestSaveAndPublishNotFound(t *testing.T) {
 
 	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
 	defer cancel()
-	err = publisher.SaveAndPublish(ctx, uuid, testHash, testAnnotations)
+	_, err = publisher.SaveAndPublish(ctx, uuid, testHash, testAnnotations)
 	assert.EqualError(t, err, ErrDraftNotFound.Error())
 
 	draftAnnotationsClient.AssertExpectations(t)
@@ -637,7 +962,7 @@ func TestSaveAndPublishNotFound(t *testing.T) {
 func TestSaveAndPublishDraftSaveAnnotationsTimeout(t *testing.T) {
 	uuid := uuid.New()
 	testHash := "hashhashhashhash"
-	testAnnotations := AnnotationsBody{[]Annotation{
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{
 		{
 			Predicate: "foo",
 			ConceptID: "bar",
@@ -656,13 +981,599 @@ func TestSaveAndPublishDraftSaveAnnotationsTimeout(t *testing.T) {
 
 	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
 	defer cancel()
-	err = publisher.SaveAndPublish(ctx, uuid, testHash, testAnnotations)
+	_, err = publisher.SaveAndPublish(ctx, uuid, testHash, testAnnotations)
 	assert.EqualError(t, err, ErrServiceTimeout.Error())
 
 	draftAnnotationsClient.AssertExpectations(t)
 	publishedAnnotationsClient.AssertExpectations(t)
 }
 
+func TestSaveAndPublishConflict(t *testing.T) {
+	uuid := uuid.New()
+	testHash := "hashhashhashhash"
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	currentAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "baz"}}}
+	conflict := &StatusError{Operation: "write to", URL: "http://www.example.com/drafts", StatusCode: http.StatusConflict}
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, testHash, testAnnotations).Return(AnnotationsBody{}, "", conflict)
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(currentAnnotations, "currenthash", nil)
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
+
+	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
+	defer cancel()
+	_, err = publisher.SaveAndPublish(ctx, uuid, testHash, testAnnotations)
+
+	assert.True(t, errors.Is(err, ErrConflict))
+	var conflictErr *ConflictError
+	require.True(t, errors.As(err, &conflictErr))
+	assert.Equal(t, conflict, conflictErr.Cause)
+	assert.Equal(t, &currentAnnotations, conflictErr.Current)
+	assert.Equal(t, "currenthash", conflictErr.CurrentHash)
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestPublishFromStoreConflictWithoutCurrentDraft(t *testing.T) {
+	uuid := uuid.New()
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	conflict := &StatusError{Operation: "write to", URL: "http://www.example.com/drafts", StatusCode: http.StatusPreconditionFailed}
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(testAnnotations, "hash", nil).Once()
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "hash", testAnnotations).Return(AnnotationsBody{}, "", conflict)
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(AnnotationsBody{}, "", errors.New("eek")).Once()
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
+
+	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
+	defer cancel()
+	_, err = publisher.PublishFromStore(ctx, uuid)
+
+	assert.EqualError(t, err, ErrConflict.Error())
+	var conflictErr *ConflictError
+	require.True(t, errors.As(err, &conflictErr))
+	assert.Nil(t, conflictErr.Current)
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestMergeAndPublish(t *testing.T) {
+	uuid := uuid.New()
+	about := Annotation{Predicate: "about", ConceptID: "a"}
+	mentions := Annotation{Predicate: "mentions", ConceptID: "b"}
+	author := Annotation{Predicate: "hasAuthor", ConceptID: "c"}
+	base := AnnotationsBody{Annotations: []Annotation{about}}
+	current := AnnotationsBody{Annotations: []Annotation{about, mentions}}
+	submitted := AnnotationsBody{Annotations: []Annotation{about, author}}
+	merged := AnnotationsBody{Annotations: []Annotation{about, mentions, author}}
+	conflict := &StatusError{Operation: "write to", URL: "http://www.example.com/drafts", StatusCode: http.StatusConflict}
+	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
+	defer cancel()
+
+	versions := &mockDraftVersions{}
+	versions.On("GetVersion", mock.Anything, uuid, "basehash").Return(base, nil)
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "basehash", submitted).Return(AnnotationsBody{}, "", conflict)
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(current, "currenthash", nil).Once()
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "currenthash", merged).Return(merged, "mergedhash", nil)
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(merged, "mergedhash", nil).Once()
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "mergedhash", merged).Return(merged, "mergedhash", nil)
+
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "mergedhash", merged).Return(merged, "mergedhash", nil)
+
+	server := startMockServer(ctx, t, uuid, true, true, time.Duration(0))
+	defer server.Close()
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithMerge(versions))
+
+	hash, err := publisher.MergeAndPublish(ctx, uuid, "basehash", submitted)
+	assert.NoError(t, err)
+	assert.Equal(t, "mergedhash", hash)
+
+	versions.AssertExpectations(t)
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestMergeAndPublishConflict(t *testing.T) {
+	uuid := uuid.New()
+	base := AnnotationsBody{Annotations: []Annotation{{Predicate: "mentions", ConceptID: "b", Type: "PERSON"}}}
+	current := AnnotationsBody{Annotations: []Annotation{{Predicate: "mentions", ConceptID: "b", Type: "ORGANISATION"}}}
+	submitted := AnnotationsBody{Annotations: []Annotation{}}
+	conflict := &StatusError{Operation: "write to", URL: "http://www.example.com/drafts", StatusCode: http.StatusConflict}
+
+	versions := &mockDraftVersions{}
+	versions.On("GetVersion", mock.Anything, uuid, "basehash").Return(base, nil)
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "basehash", submitted).Return(AnnotationsBody{}, "", conflict)
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(current, "currenthash", nil)
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithMerge(versions))
+
+	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
+	defer cancel()
+	_, err = publisher.MergeAndPublish(ctx, uuid, "basehash", submitted)
+
+	var conflictErr *ConflictError
+	require.True(t, errors.As(err, &conflictErr))
+	assert.Equal(t, &current, conflictErr.Current)
+	assert.Equal(t, "currenthash", conflictErr.CurrentHash)
+	require.Len(t, conflictErr.Conflicts, 1)
+	assert.Equal(t, "b", conflictErr.Conflicts[0].ConceptID)
+	assert.Nil(t, conflictErr.Conflicts[0].Submitted)
+
+	versions.AssertExpectations(t)
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestMergeAndPublishUnknownBaseVersion(t *testing.T) {
+	uuid := uuid.New()
+	submitted := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	current := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "baz"}}}
+	conflict := &StatusError{Operation: "write to", URL: "http://www.example.com/drafts", StatusCode: http.StatusConflict}
+
+	versions := &mockDraftVersions{}
+	versions.On("GetVersion", mock.Anything, uuid, "basehash").Return(AnnotationsBody{}, ErrVersionNotFound)
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "basehash", submitted).Return(AnnotationsBody{}, "", conflict)
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(current, "currenthash", nil)
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithMerge(versions))
+
+	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
+	defer cancel()
+	_, err = publisher.MergeAndPublish(ctx, uuid, "basehash", submitted)
+
+	var conflictErr *ConflictError
+	require.True(t, errors.As(err, &conflictErr))
+	assert.Equal(t, conflict, conflictErr.Cause)
+	assert.Equal(t, &current, conflictErr.Current)
+	assert.Empty(t, conflictErr.Conflicts)
+
+	versions.AssertExpectations(t)
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestSaveAndPublishIsAudited(t *testing.T) {
+	uuid := uuid.New()
+	previous := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "baz"}}}
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
+	defer cancel()
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "hash", testAnnotations).Return(testAnnotations, "newhash", nil)
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(testAnnotations, "newhash", nil)
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "newhash", testAnnotations).Return(testAnnotations, "newhash", nil)
+
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(previous, "hash", nil)
+	publishedAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "newhash", testAnnotations).Return(testAnnotations, "newhash", nil)
+
+	auditor := &mockPublishAuditor{}
+	auditor.On("Record", mock.Anything, mock.MatchedBy(func(attempt PublishAttempt) bool {
+		return attempt.UUID == uuid && !attempt.FromStore && attempt.OriginSystemID == "originSystemID" &&
+			attempt.PreviousHash == "hash" && attempt.NewHash == "newhash" && attempt.Err == nil &&
+			assert.ObjectsAreEqual(&previous, attempt.Previous) && assert.ObjectsAreEqual(&testAnnotations, attempt.Published)
+	})).Return(nil)
+
+	server := startMockServer(ctx, t, uuid, true, true, time.Duration(0))
+	defer server.Close()
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithPublishAuditor(auditor))
+
+	hash, err := publisher.SaveAndPublish(ctx, uuid, "hash", testAnnotations)
+	assert.NoError(t, err)
+	assert.Equal(t, "newhash", hash)
+
+	auditor.AssertExpectations(t)
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestFailedPublishFromStoreIsAudited(t *testing.T) {
+	uuid := uuid.New()
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(AnnotationsBody{}, "", ErrDraftNotFound)
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+
+	auditor := &mockPublishAuditor{}
+	auditor.On("Record", mock.Anything, mock.MatchedBy(func(attempt PublishAttempt) bool {
+		return attempt.UUID == uuid && attempt.FromStore && attempt.Err == ErrDraftNotFound && attempt.Published == nil
+	})).Return(errors.New("audit failures do not fail the publish"))
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithPublishAuditor(auditor))
+
+	_, err = publisher.PublishFromStore(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid)
+	assert.Equal(t, ErrDraftNotFound, err)
+
+	auditor.AssertExpectations(t)
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestPublishFromStoreIsObserved(t *testing.T) {
+	uuid := uuid.New()
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), "tid_test"), 50*time.Millisecond)
+	defer cancel()
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(testAnnotations, "hash", nil)
+	draftAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "hash", testAnnotations).Return(testAnnotations, "newhash", nil)
+
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("SaveAnnotations", mock.Anything, uuid, "newhash", testAnnotations).Return(testAnnotations, "newhash", nil)
+
+	server := startMockServer(ctx, t, uuid, true, true, time.Duration(0))
+	defer server.Close()
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	observer := &recordingObserver{}
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithPublishObserver(observer))
+
+	hash, err := publisher.PublishFromStore(ctx, uuid)
+	assert.NoError(t, err)
+	assert.Equal(t, "newhash", hash)
+
+	assert.Equal(t, []Stage{StageDraftRead, StageDraftSave, StagePublishedSave, StageUPPPublish}, observer.stages)
+	assert.Equal(t, []error{nil, nil, nil, nil}, observer.stageErrs)
+	require.Len(t, observer.publishes, 1)
+	assert.True(t, observer.publishes[0].FromStore)
+	assert.NoError(t, observer.publishes[0].Err)
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestFailedPublishFromStoreIsObserved(t *testing.T) {
+	uuid := uuid.New()
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(AnnotationsBody{}, "", ErrDraftNotFound)
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	observer := &recordingObserver{}
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithPublishObserver(observer))
+
+	_, err = publisher.PublishFromStore(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid)
+	assert.Equal(t, ErrDraftNotFound, err)
+
+	assert.Equal(t, []Stage{StageDraftRead}, observer.stages)
+	assert.Equal(t, []error{ErrDraftNotFound}, observer.stageErrs)
+	require.Len(t, observer.publishes, 1)
+	assert.Equal(t, ErrDraftNotFound, observer.publishes[0].Err)
+
+	draftAnnotationsClient.AssertExpectations(t)
+}
+
+func TestUnpublish(t *testing.T) {
+	uuid := uuid.New()
+	previous := AnnotationsBody{Annotations: []Annotation{{Predicate: "about", ConceptID: "a"}}}
+	ctx := tid.TransactionAwareContext(context.Background(), "tid_test")
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(previous, "hash", nil)
+	publishedAnnotationsClient.On("DeleteAnnotations", mock.Anything, uuid).Return(nil)
+
+	auditor := &mockPublishAuditor{}
+	auditor.On("Record", mock.Anything, mock.MatchedBy(func(attempt PublishAttempt) bool {
+		return attempt.UUID == uuid && attempt.Unpublish && attempt.Err == nil &&
+			assert.ObjectsAreEqual(&previous, attempt.Previous) && len(attempt.Published.Annotations) == 0
+	})).Return(nil)
+
+	server := startMockServer(ctx, t, uuid, true, true, time.Duration(0))
+	defer server.Close()
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithPublishAuditor(auditor))
+
+	err = publisher.Unpublish(ctx, uuid)
+	assert.NoError(t, err)
+
+	auditor.AssertExpectations(t)
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestUnpublishNeverPublished(t *testing.T) {
+	uuid := uuid.New()
+	ctx := tid.TransactionAwareContext(context.Background(), "tid_test")
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("DeleteAnnotations", mock.Anything, uuid).Return(ErrDraftNotFound)
+
+	server := startMockServer(ctx, t, uuid, true, true, time.Duration(0))
+	defer server.Close()
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
+
+	err = publisher.Unpublish(ctx, uuid)
+	assert.NoError(t, err, "content should be unpublished from UPP even if PAC has never published it")
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestUnpublishDeleteFails(t *testing.T) {
+	uuid := uuid.New()
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("DeleteAnnotations", mock.Anything, uuid).Return(testTimeoutError{errors.New("timeout")})
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
+
+	err = publisher.Unpublish(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid)
+	assert.Equal(t, ErrServiceTimeout, err)
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestRepublish(t *testing.T) {
+	uuid := uuid.New()
+	published := AnnotationsBody{Annotations: []Annotation{{Predicate: "about", ConceptID: "a"}}}
+	ctx := tid.TransactionAwareContext(context.Background(), "tid_test")
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(published, "hash", nil)
+
+	auditor := &mockPublishAuditor{}
+	auditor.On("Record", mock.Anything, mock.MatchedBy(func(attempt PublishAttempt) bool {
+		return attempt.UUID == uuid && attempt.Republish && attempt.Err == nil && attempt.NewHash == "hash" &&
+			assert.ObjectsAreEqual(&published, attempt.Published)
+	})).Return(nil)
+
+	server := startMockServer(ctx, t, uuid, true, true, time.Duration(0))
+	defer server.Close()
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, server.URL+"/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithPublishAuditor(auditor))
+
+	hash, err := publisher.Republish(ctx, uuid)
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", hash)
+
+	auditor.AssertExpectations(t)
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestRepublishNeverPublished(t *testing.T) {
+	uuid := uuid.New()
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(AnnotationsBody{}, "", ErrDraftNotFound)
+
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
+
+	_, err = publisher.Republish(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid)
+	assert.Equal(t, ErrNotPublished, err)
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestUnpublishedChanges(t *testing.T) {
+	uuid := uuid.New()
+	draft := AnnotationsBody{Annotations: []Annotation{{Predicate: "about", ConceptID: "a"}, {Predicate: "hasAuthor", ConceptID: "c"}}}
+	published := AnnotationsBody{Annotations: []Annotation{{Predicate: "about", ConceptID: "a"}, {Predicate: "mentions", ConceptID: "b"}}}
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(draft, "drafthash", nil)
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(published, "publishedhash", nil)
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
+
+	diff, hash, err := publisher.UnpublishedChanges(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid)
+	require.NoError(t, err)
+	assert.Equal(t, "drafthash", hash)
+	assert.Equal(t, []Annotation{{Predicate: "hasAuthor", ConceptID: "c"}}, diff.Added)
+	assert.Equal(t, []Annotation{{Predicate: "mentions", ConceptID: "b"}}, diff.Removed)
+	assert.Empty(t, diff.Changed)
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestUnpublishedChangesOfUnpublishedContent(t *testing.T) {
+	uuid := uuid.New()
+	draft := AnnotationsBody{Annotations: []Annotation{{Predicate: "about", ConceptID: "a"}}}
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(draft, "drafthash", nil)
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	publishedAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(AnnotationsBody{}, "", ErrDraftNotFound)
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
+
+	diff, _, err := publisher.UnpublishedChanges(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid)
+	require.NoError(t, err)
+	assert.Equal(t, draft.Annotations, diff.Added)
+	assert.Empty(t, diff.Removed)
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestPreviewPublishFromStore(t *testing.T) {
+	uuid := uuid.New()
+	draft := AnnotationsBody{Annotations: []Annotation{{Predicate: "about", ConceptID: "a"}}}
+
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(draft, "drafthash", nil)
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
+
+	payload, err := publisher.PreviewPublish(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid, "", nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"annotations": draft.Annotations, "uuid": uuid}, payload)
+
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
+func TestPreviewSaveAndPublish(t *testing.T) {
+	uuid := uuid.New()
+	current := AnnotationsBody{Annotations: []Annotation{{Predicate: "about", ConceptID: "a"}}}
+	submitted := AnnotationsBody{Annotations: []Annotation{{Predicate: "about", ConceptID: "a"}, {Predicate: "mentions", ConceptID: "b"}}}
+
+	tests := map[string]struct {
+		hash     string
+		expected map[string]interface{}
+		conflict bool
+	}{
+		"without hash": {
+			hash:     "",
+			expected: map[string]interface{}{"annotations": submitted.Annotations, "uuid": uuid},
+		},
+		"with current hash": {
+			hash:     "currenthash",
+			expected: map[string]interface{}{"annotations": submitted.Annotations, "uuid": uuid},
+		},
+		"with outdated hash": {
+			hash:     "oldhash",
+			conflict: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			draftAnnotationsClient := &mockAnnotationsClient{}
+			if test.hash != "" {
+				draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(current, "currenthash", nil)
+			}
+			publishedAnnotationsClient := &mockAnnotationsClient{}
+			testingClient, err := fthttp.NewClient(
+				fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+			)
+			require.NoError(t, err)
+			publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"))
+
+			payload, err := publisher.PreviewPublish(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid, test.hash, &submitted, false)
+			if test.conflict {
+				var conflictErr *ConflictError
+				require.True(t, errors.As(err, &conflictErr))
+				assert.Equal(t, &current, conflictErr.Current)
+				assert.Equal(t, "currenthash", conflictErr.CurrentHash)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, payload)
+			}
+
+			draftAnnotationsClient.AssertExpectations(t)
+			publishedAnnotationsClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPreviewMergeAndPublish(t *testing.T) {
+	uuid := uuid.New()
+	about := Annotation{Predicate: "about", ConceptID: "a"}
+	mentions := Annotation{Predicate: "mentions", ConceptID: "b"}
+	author := Annotation{Predicate: "hasAuthor", ConceptID: "c"}
+	submitted := AnnotationsBody{Annotations: []Annotation{about, author}}
+
+	versions := &mockDraftVersions{}
+	versions.On("GetVersion", mock.Anything, uuid, "basehash").Return(AnnotationsBody{Annotations: []Annotation{about}}, nil)
+	draftAnnotationsClient := &mockAnnotationsClient{}
+	draftAnnotationsClient.On("GetAnnotations", mock.Anything, uuid).Return(AnnotationsBody{Annotations: []Annotation{about, mentions}}, "currenthash", nil)
+	publishedAnnotationsClient := &mockAnnotationsClient{}
+	testingClient, err := fthttp.NewClient(
+		fthttp.WithSysInfo("PAC", "test-annotations-publisher"),
+	)
+	require.NoError(t, err)
+	publisher := NewPublisher("originSystemID", draftAnnotationsClient, publishedAnnotationsClient, "http://www.example.com/notify", "user:pass", "http://www.example.com/__gtg", testingClient, logger.NewUPPLogger("test", "DEBUG"), WithMerge(versions))
+
+	payload, err := publisher.PreviewPublish(tid.TransactionAwareContext(context.Background(), "tid_test"), uuid, "basehash", &submitted, true)
+	require.NoError(t, err)
+	assert.Equal(t, []Annotation{about, mentions, author}, payload["annotations"])
+
+	versions.AssertExpectations(t)
+	draftAnnotationsClient.AssertExpectations(t)
+	publishedAnnotationsClient.AssertExpectations(t)
+}
+
 func startMockServer(ctx context.Context, t *testing.T, uuid string, publishOk bool, gtgOk bool, delay time.Duration) *httptest.Server {
 	r := vestigo.NewRouter()
 	r.Get("/__gtg", func(w http.ResponseWriter, r *http.Request) {
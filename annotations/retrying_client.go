@@ -0,0 +1,149 @@
+package annotations
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/breaker"
+	"github.com/Financial-Times/annotations-publisher/bulkhead"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+)
+
+// RetryPolicy configures how the calls of a retrying AnnotationsClient are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts of a call, including the first one
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction of the backoff which is randomly added or removed, between 0 and 1
+	Jitter float64
+	// RetryableStatusCodes are the r/w service responses which are worth another attempt
+	RetryableStatusCodes []int
+}
+
+type retryingClient struct {
+	AnnotationsClient
+	policy    RetryPolicy
+	retryable map[int]bool
+	log       *logger.UPPLogger
+	sleep     func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryingAnnotationsClient decorates the client with retries of failed calls according to the policy.
+// Conflicting writes are never retried, and a write with a Previous-Document-Hash which conflicts after an earlier attempt failed
+// is only considered successful if the store now holds the written annotations.
+func NewRetryingAnnotationsClient(client AnnotationsClient, policy RetryPolicy, log *logger.UPPLogger) AnnotationsClient {
+	retryable := make(map[int]bool, len(policy.RetryableStatusCodes))
+	for _, code := range policy.RetryableStatusCodes {
+		retryable[code] = true
+	}
+	return &retryingClient{AnnotationsClient: client, policy: policy, retryable: retryable, log: log, sleep: sleep}
+}
+
+func (rc *retryingClient) GetAnnotations(ctx context.Context, uuid string) (AnnotationsBody, string, error) {
+	var ann AnnotationsBody
+	var hash string
+	err := rc.retry(ctx, uuid, "read", func(attempt int) error {
+		var err error
+		ann, hash, err = rc.AnnotationsClient.GetAnnotations(ctx, uuid)
+		return err
+	})
+	return ann, hash, err
+}
+
+func (rc *retryingClient) SaveAnnotations(ctx context.Context, uuid string, hash string, data AnnotationsBody) (AnnotationsBody, string, error) {
+	var ann AnnotationsBody
+	var newHash string
+	err := rc.retry(ctx, uuid, "write", func(attempt int) error {
+		var err error
+		ann, newHash, err = rc.AnnotationsClient.SaveAnnotations(ctx, uuid, hash, data)
+		if attempt > 1 && hash != "" && errors.Is(err, ErrConflict) {
+			// an earlier attempt may have been applied even though it failed, which moved the hash on
+			ann, newHash, err = rc.verifyWrite(ctx, uuid, data, err)
+		}
+		return err
+	})
+	return ann, newHash, err
+}
+
+func (rc *retryingClient) DeleteAnnotations(ctx context.Context, uuid string) error {
+	return rc.retry(ctx, uuid, "delete", func(attempt int) error {
+		return rc.AnnotationsClient.DeleteAnnotations(ctx, uuid)
+	})
+}
+
+// verifyWrite checks whether the store holds the written data after a conflicting retry, and returns the conflict if it does not
+func (rc *retryingClient) verifyWrite(ctx context.Context, uuid string, data AnnotationsBody, conflict error) (AnnotationsBody, string, error) {
+	current, currentHash, err := rc.AnnotationsClient.GetAnnotations(ctx, uuid)
+	if err != nil || !sameAnnotations(current, data) {
+		return AnnotationsBody{}, "", conflict
+	}
+	return current, currentHash, nil
+}
+
+func (rc *retryingClient) retry(ctx context.Context, uuid string, operation string, call func(attempt int) error) error {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	mlog := rc.log.WithTransactionID(txid).WithUUID(uuid).WithField("endpoint", rc.Endpoint())
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = call(attempt)
+		if err == nil || attempt >= rc.policy.MaxAttempts || !rc.isRetryable(ctx, err) {
+			return err
+		}
+
+		backoff := rc.backoff(attempt)
+		mlog.WithError(err).WithField("attempt", attempt).WithField("backoff", backoff.String()).Warnf("annotations %s failed, retrying", operation)
+		if rc.sleep(ctx, backoff) != nil {
+			return err
+		}
+	}
+}
+
+func (rc *retryingClient) isRetryable(ctx context.Context, err error) bool {
+	// an open circuit breaker rejects every attempt until it lets requests through again, and a full bulkhead is already shedding load
+	if ctx.Err() != nil || errors.Is(err, ErrConflict) || errors.Is(err, breaker.ErrOpen) || errors.Is(err, bulkhead.ErrFull) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return rc.retryable[statusErr.StatusCode]
+	}
+
+	// connection resets, refused connections and client side timeouts
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (rc *retryingClient) backoff(attempt int) time.Duration {
+	backoff := rc.policy.InitialBackoff
+	for i := 1; i < attempt && backoff < rc.policy.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if rc.policy.MaxBackoff > 0 && backoff > rc.policy.MaxBackoff {
+		backoff = rc.policy.MaxBackoff
+	}
+
+	if rc.policy.Jitter > 0 {
+		jitter := (rand.Float64()*2 - 1) * rc.policy.Jitter * float64(backoff)
+		backoff += time.Duration(jitter)
+	}
+	return backoff
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,181 @@
+package annotations
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/breaker"
+	"github.com/Financial-Times/annotations-publisher/bulkhead"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:          3,
+	InitialBackoff:       100 * time.Millisecond,
+	MaxBackoff:           150 * time.Millisecond,
+	RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+}
+
+func newTestRetryingClient(client AnnotationsClient, policy RetryPolicy) (*retryingClient, *[]time.Duration) {
+	rc := NewRetryingAnnotationsClient(client, policy, logger.NewUPPLogger("test", "DEBUG")).(*retryingClient)
+	var backoffs []time.Duration
+	rc.sleep = func(ctx context.Context, d time.Duration) error {
+		backoffs = append(backoffs, d)
+		return ctx.Err()
+	}
+	return rc, &backoffs
+}
+
+func unavailable() error {
+	return &StatusError{Operation: "read from", URL: "http://localhost", StatusCode: http.StatusServiceUnavailable}
+}
+
+func conflict() error {
+	return &StatusError{Operation: "write to", URL: "http://localhost", StatusCode: http.StatusConflict}
+}
+
+func TestRetryingGetAnnotationsSucceedsAfterRetries(t *testing.T) {
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	client := &mockAnnotationsClient{}
+	client.On("GetAnnotations", mock.Anything, "a-valid-uuid").Return(AnnotationsBody{}, "", unavailable()).Twice()
+	client.On("GetAnnotations", mock.Anything, "a-valid-uuid").Return(testAnnotations, "hash", nil).Once()
+
+	rc, backoffs := newTestRetryingClient(client, testRetryPolicy)
+	ann, hash, err := rc.GetAnnotations(context.Background(), "a-valid-uuid")
+
+	assert.NoError(t, err)
+	assert.Equal(t, testAnnotations, ann)
+	assert.Equal(t, "hash", hash)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 150 * time.Millisecond}, *backoffs)
+	client.AssertExpectations(t)
+}
+
+func TestRetryingGetAnnotationsGivesUp(t *testing.T) {
+	client := &mockAnnotationsClient{}
+	client.On("GetAnnotations", mock.Anything, "a-valid-uuid").Return(AnnotationsBody{}, "", unavailable()).Times(3)
+
+	rc, _ := newTestRetryingClient(client, testRetryPolicy)
+	_, _, err := rc.GetAnnotations(context.Background(), "a-valid-uuid")
+
+	assert.Equal(t, unavailable(), err)
+	client.AssertExpectations(t)
+}
+
+func TestRetryingGetAnnotationsDoesNotRetry(t *testing.T) {
+	tests := map[string]error{
+		"not found":                 ErrDraftNotFound,
+		"non retryable status code": &StatusError{Operation: "read from", URL: "http://localhost", StatusCode: http.StatusInternalServerError},
+		"unknown error":             errors.New("eek"),
+		"open circuit breaker":      &url.Error{Op: "Get", URL: "http://localhost", Err: &breaker.OpenError{Name: "draft-annotations-rw", RetryAfter: time.Second}},
+		"full bulkhead":             &url.Error{Op: "Get", URL: "http://localhost", Err: &bulkhead.FullError{Name: "draft-annotations-rw"}},
+	}
+
+	for name, expected := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &mockAnnotationsClient{}
+			client.On("GetAnnotations", mock.Anything, "a-valid-uuid").Return(AnnotationsBody{}, "", expected).Once()
+
+			rc, backoffs := newTestRetryingClient(client, testRetryPolicy)
+			_, _, err := rc.GetAnnotations(context.Background(), "a-valid-uuid")
+
+			assert.Equal(t, expected, err)
+			assert.Empty(t, *backoffs)
+			client.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRetryingRetriesConnectionErrors(t *testing.T) {
+	client := &mockAnnotationsClient{}
+	client.On("GetAnnotations", mock.Anything, "a-valid-uuid").Return(AnnotationsBody{}, "", testTimeoutError{errors.New("connection reset")}).Once()
+	client.On("GetAnnotations", mock.Anything, "a-valid-uuid").Return(AnnotationsBody{}, "hash", nil).Once()
+
+	rc, _ := newTestRetryingClient(client, testRetryPolicy)
+	_, hash, err := rc.GetAnnotations(context.Background(), "a-valid-uuid")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", hash)
+	client.AssertExpectations(t)
+}
+
+func TestRetryingStopsWhenContextIsDone(t *testing.T) {
+	client := &mockAnnotationsClient{}
+	client.On("GetAnnotations", mock.Anything, "a-valid-uuid").Return(AnnotationsBody{}, "", unavailable()).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rc, _ := newTestRetryingClient(client, testRetryPolicy)
+	_, _, err := rc.GetAnnotations(ctx, "a-valid-uuid")
+
+	assert.Equal(t, unavailable(), err)
+	client.AssertExpectations(t)
+}
+
+func TestRetryingSaveAnnotationsNeverRetriesConflicts(t *testing.T) {
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	policy := testRetryPolicy
+	policy.RetryableStatusCodes = []int{http.StatusConflict}
+
+	client := &mockAnnotationsClient{}
+	client.On("SaveAnnotations", mock.Anything, "a-valid-uuid", "hash", testAnnotations).Return(AnnotationsBody{}, "", conflict()).Once()
+
+	rc, backoffs := newTestRetryingClient(client, policy)
+	_, _, err := rc.SaveAnnotations(context.Background(), "a-valid-uuid", "hash", testAnnotations)
+
+	assert.Equal(t, conflict(), err)
+	assert.Empty(t, *backoffs)
+	client.AssertExpectations(t)
+}
+
+func TestRetryingSaveAnnotationsConflictAfterAppliedAttempt(t *testing.T) {
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	enriched := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar", PrefLabel: "Bar"}}}
+
+	client := &mockAnnotationsClient{}
+	client.On("SaveAnnotations", mock.Anything, "a-valid-uuid", "hash", testAnnotations).Return(AnnotationsBody{}, "", unavailable()).Once()
+	client.On("SaveAnnotations", mock.Anything, "a-valid-uuid", "hash", testAnnotations).Return(AnnotationsBody{}, "", conflict()).Once()
+	client.On("GetAnnotations", mock.Anything, "a-valid-uuid").Return(enriched, "newhash", nil).Once()
+
+	rc, _ := newTestRetryingClient(client, testRetryPolicy)
+	ann, hash, err := rc.SaveAnnotations(context.Background(), "a-valid-uuid", "hash", testAnnotations)
+
+	assert.NoError(t, err)
+	assert.Equal(t, enriched, ann)
+	assert.Equal(t, "newhash", hash)
+	client.AssertExpectations(t)
+}
+
+func TestRetryingSaveAnnotationsConflictWithConcurrentWrite(t *testing.T) {
+	testAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	otherAnnotations := AnnotationsBody{Annotations: []Annotation{{Predicate: "foo", ConceptID: "baz"}}}
+
+	client := &mockAnnotationsClient{}
+	client.On("SaveAnnotations", mock.Anything, "a-valid-uuid", "hash", testAnnotations).Return(AnnotationsBody{}, "", unavailable()).Once()
+	client.On("SaveAnnotations", mock.Anything, "a-valid-uuid", "hash", testAnnotations).Return(AnnotationsBody{}, "", conflict()).Once()
+	client.On("GetAnnotations", mock.Anything, "a-valid-uuid").Return(otherAnnotations, "otherhash", nil).Once()
+
+	rc, _ := newTestRetryingClient(client, testRetryPolicy)
+	_, _, err := rc.SaveAnnotations(context.Background(), "a-valid-uuid", "hash", testAnnotations)
+
+	assert.Equal(t, conflict(), err)
+	client.AssertExpectations(t)
+}
+
+func TestRetryingBackoffJitter(t *testing.T) {
+	policy := testRetryPolicy
+	policy.Jitter = 0.5
+	rc, _ := newTestRetryingClient(&mockAnnotationsClient{}, policy)
+
+	for i := 0; i < 100; i++ {
+		backoff := rc.backoff(1)
+		assert.GreaterOrEqual(t, backoff, 50*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 150*time.Millisecond)
+	}
+}
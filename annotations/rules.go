@@ -0,0 +1,91 @@
+package annotations
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rules declares how every predicate can be used, keyed by predicate. Predicates without a rule can be used with any concept, any number of times.
+type Rules struct {
+	Predicates map[string]PredicateRule `yaml:"predicates"`
+}
+
+// PredicateRule constrains the annotations with a single predicate
+type PredicateRule struct {
+	// Types are the concept types the predicate can be used with. Any type is allowed if empty.
+	Types []string `yaml:"types"`
+	// MaxOccurrences is the number of annotations with the predicate allowed on a piece of content. There is no limit if zero.
+	MaxOccurrences int `yaml:"maxOccurrences"`
+}
+
+// RulesValidator checks annotations against Rules
+type RulesValidator struct {
+	rules Rules
+	types map[string]map[string]bool
+}
+
+// NewRulesValidator returns a RulesValidator which enforces the given rules
+func NewRulesValidator(rules Rules) *RulesValidator {
+	types := make(map[string]map[string]bool, len(rules.Predicates))
+	for predicate, rule := range rules.Predicates {
+		types[predicate] = toSet(rule.Types)
+	}
+	return &RulesValidator{rules: rules, types: types}
+}
+
+// LoadRulesValidator returns a RulesValidator which enforces the rules in the given YAML file
+func LoadRulesValidator(path string) (*RulesValidator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules Rules
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("failed to read annotation rules from %v: %w", path, err)
+	}
+	for predicate, rule := range rules.Predicates {
+		if rule.MaxOccurrences < 0 {
+			return nil, fmt.Errorf("maxOccurrences of %v cannot be negative", predicate)
+		}
+	}
+	return NewRulesValidator(rules), nil
+}
+
+// Validate checks the type of every annotation with a rule, and the number of annotations with every predicate.
+// Annotations without a type are not checked against the allowed types, as the type is only known once the r/w service has enriched them.
+func (v *RulesValidator) Validate(body AnnotationsBody) []FieldError {
+	var fieldErrs []FieldError
+	occurrences := make(map[string]int)
+	for i, ann := range body.Annotations {
+		rule, ok := v.rules.Predicates[ann.Predicate]
+		if !ok {
+			continue
+		}
+
+		types := v.types[ann.Predicate]
+		if ann.Type != "" && len(types) > 0 && !types[ann.Type] {
+			fieldErrs = append(fieldErrs, FieldError{
+				Field:   fmt.Sprintf("annotations[%d].type", i),
+				Value:   ann.Type,
+				Message: fmt.Sprintf("cannot be used with predicate %v, which only allows %v", ann.Predicate, strings.Join(rule.Types, ", ")),
+			})
+		}
+
+		occurrences[ann.Predicate]++
+		if rule.MaxOccurrences > 0 && occurrences[ann.Predicate] == rule.MaxOccurrences+1 {
+			fieldErrs = append(fieldErrs, FieldError{
+				Field:   fmt.Sprintf("annotations[%d].predicate", i),
+				Value:   ann.Predicate,
+				Message: fmt.Sprintf("cannot be used more than %d time(s)", rule.MaxOccurrences),
+			})
+		}
+	}
+	return fieldErrs
+}
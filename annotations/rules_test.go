@@ -0,0 +1,72 @@
+package annotations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesValidator(t *testing.T) {
+	v := NewRulesValidator(Rules{Predicates: map[string]PredicateRule{
+		"hasAuthor":               {Types: []string{"PERSON"}},
+		"isPrimarilyClassifiedBy": {Types: []string{"BRAND", "GENRE"}, MaxOccurrences: 1},
+	}})
+
+	tests := map[string]struct {
+		annotations []Annotation
+		expected    []FieldError
+	}{
+		"allowed types": {
+			annotations: []Annotation{
+				{Predicate: "hasAuthor", ConceptID: "a", Type: "PERSON"},
+				{Predicate: "isPrimarilyClassifiedBy", ConceptID: "b", Type: "GENRE"},
+				{Predicate: "mentions", ConceptID: "c", Type: "ORGANISATION"},
+			},
+		},
+		"annotations without a type": {
+			annotations: []Annotation{{Predicate: "hasAuthor", ConceptID: "a"}},
+		},
+		"type not allowed for the predicate": {
+			annotations: []Annotation{{Predicate: "hasAuthor", ConceptID: "a", Type: "ORGANISATION"}},
+			expected:    []FieldError{{Field: "annotations[0].type", Value: "ORGANISATION", Message: "cannot be used with predicate hasAuthor, which only allows PERSON"}},
+		},
+		"too many occurrences": {
+			annotations: []Annotation{
+				{Predicate: "isPrimarilyClassifiedBy", ConceptID: "a"},
+				{Predicate: "isPrimarilyClassifiedBy", ConceptID: "b"},
+				{Predicate: "isPrimarilyClassifiedBy", ConceptID: "c"},
+			},
+			expected: []FieldError{{Field: "annotations[1].predicate", Value: "isPrimarilyClassifiedBy", Message: "cannot be used more than 1 time(s)"}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, v.Validate(AnnotationsBody{Annotations: test.annotations}))
+		})
+	}
+}
+
+func TestLoadRulesValidator(t *testing.T) {
+	v, err := LoadRulesValidator("../annotation-rules.yml")
+	require.NoError(t, err)
+
+	fieldErrs := v.Validate(AnnotationsBody{Annotations: []Annotation{
+		{Predicate: "http://www.ft.com/ontology/annotation/hasAuthor", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd", Type: "BRAND"},
+	}})
+	assert.Len(t, fieldErrs, 1)
+}
+
+func TestLoadRulesValidatorInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yml")
+	require.NoError(t, os.WriteFile(path, []byte("predicates:\n  hasAuthor:\n    type: [PERSON]\n"), 0600))
+
+	_, err := LoadRulesValidator(path)
+	assert.Error(t, err, "unknown fields should be rejected")
+
+	_, err = LoadRulesValidator(filepath.Join(t.TempDir(), "missing.yml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package annotations
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidAnnotations occurs when annotations are rejected by a Validator before they are saved or published
+var ErrInvalidAnnotations = errors.New("annotations are invalid")
+
+// FieldError describes why the value of a single field of the annotations is invalid
+type FieldError struct {
+	// Field is the path of the field within the annotations body, such as annotations[2].id
+	Field   string `json:"field"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError occurs when annotations are rejected by a Validator, and holds every invalid field
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		msgs = append(msgs, fieldErr.Field+" "+fieldErr.Message)
+	}
+	return ErrInvalidAnnotations.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidAnnotations
+}
+
+// Validator checks annotations before they are saved or published, returning every invalid field
+type Validator interface {
+	Validate(body AnnotationsBody) []FieldError
+}
+
+// DefaultPredicates are the predicates accepted by default in published annotations
+var DefaultPredicates = []string{
+	"http://www.ft.com/ontology/annotation/about",
+	"http://www.ft.com/ontology/annotation/hasAuthor",
+	"http://www.ft.com/ontology/annotation/hasContributor",
+	"http://www.ft.com/ontology/annotation/hasDisplayTag",
+	"http://www.ft.com/ontology/annotation/implicitlyAbout",
+	"http://www.ft.com/ontology/annotation/implicitlyClassifiedBy",
+	"http://www.ft.com/ontology/annotation/mentions",
+	"http://www.ft.com/ontology/classification/isClassifiedBy",
+	"http://www.ft.com/ontology/classification/isPrimarilyClassifiedBy",
+	"http://www.ft.com/ontology/hasBrand",
+	"http://www.ft.com/ontology/hasContributor",
+}
+
+// DefaultConceptTypes are the concept types accepted by default in published annotations
+var DefaultConceptTypes = []string{"BRAND", "GENRE", "LOCATION", "ORGANISATION", "PERSON", "PUBLIC_COMPANY", "SECTION", "SPECIAL_REPORT", "SUBJECT", "TOPIC"}
+
+var conceptURIRegex = regexp.MustCompile(`^https?://[^/\s]+/(?:[^\s]*/)?[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// SchemaValidator checks the predicate, concept ID and type of every annotation, and that no annotation is present more than once
+type SchemaValidator struct {
+	predicates map[string]bool
+	types      map[string]bool
+}
+
+// NewSchemaValidator returns a SchemaValidator which only accepts the given predicates and concept types.
+// Any predicate is accepted if predicates is empty, and any type if types is empty. Annotations without a type are always accepted.
+func NewSchemaValidator(predicates []string, types []string) *SchemaValidator {
+	return &SchemaValidator{predicates: toSet(predicates), types: toSet(types)}
+}
+
+func (v *SchemaValidator) Validate(body AnnotationsBody) []FieldError {
+	var fieldErrs []FieldError
+	seen := make(map[string]int, len(body.Annotations))
+	for i, ann := range body.Annotations {
+		field := fmt.Sprintf("annotations[%d]", i)
+
+		switch {
+		case ann.Predicate == "":
+			fieldErrs = append(fieldErrs, FieldError{Field: field + ".predicate", Message: "is required"})
+		case len(v.predicates) > 0 && !v.predicates[ann.Predicate]:
+			fieldErrs = append(fieldErrs, FieldError{Field: field + ".predicate", Value: ann.Predicate, Message: "is not an allowed predicate"})
+		}
+
+		switch {
+		case ann.ConceptID == "":
+			fieldErrs = append(fieldErrs, FieldError{Field: field + ".id", Message: "is required"})
+		case !conceptURIRegex.MatchString(ann.ConceptID):
+			fieldErrs = append(fieldErrs, FieldError{Field: field + ".id", Value: ann.ConceptID, Message: "is not a concept URI ending in a UUID"})
+		}
+
+		if ann.Type != "" && len(v.types) > 0 && !v.types[ann.Type] {
+			fieldErrs = append(fieldErrs, FieldError{Field: field + ".type", Value: ann.Type, Message: "is not a known concept type"})
+		}
+
+		key := annotationKey(ann)
+		if first, ok := seen[key]; ok {
+			fieldErrs = append(fieldErrs, FieldError{Field: field, Message: fmt.Sprintf("has the same predicate and id as annotations[%d]", first)})
+			continue
+		}
+		seen[key] = i
+	}
+	return fieldErrs
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
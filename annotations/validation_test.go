@@ -0,0 +1,120 @@
+package annotations
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSchemaValidator(t *testing.T) {
+	v := NewSchemaValidator([]string{"http://www.ft.com/ontology/annotation/about", "http://www.ft.com/ontology/annotation/mentions"}, []string{"PERSON", "TOPIC"})
+
+	tests := map[string]struct {
+		annotations []Annotation
+		expected    []FieldError
+	}{
+		"valid annotations": {
+			annotations: []Annotation{
+				{Predicate: "http://www.ft.com/ontology/annotation/about", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd"},
+				{Predicate: "http://www.ft.com/ontology/annotation/mentions", ConceptID: "http://api.ft.com/things/5bd49568-6d7c-3c10-a5b0-2f3fd5974a6b", Type: "PERSON"},
+			},
+		},
+		"unknown predicate": {
+			annotations: []Annotation{{Predicate: "http://www.ft.com/ontology/annotation/likes", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd"}},
+			expected:    []FieldError{{Field: "annotations[0].predicate", Value: "http://www.ft.com/ontology/annotation/likes", Message: "is not an allowed predicate"}},
+		},
+		"missing fields": {
+			annotations: []Annotation{{}},
+			expected: []FieldError{
+				{Field: "annotations[0].predicate", Message: "is required"},
+				{Field: "annotations[0].id", Message: "is required"},
+			},
+		},
+		"concept ID without a UUID": {
+			annotations: []Annotation{{Predicate: "http://www.ft.com/ontology/annotation/about", ConceptID: "http://www.ft.com/thing/not-a-uuid"}},
+			expected:    []FieldError{{Field: "annotations[0].id", Value: "http://www.ft.com/thing/not-a-uuid", Message: "is not a concept URI ending in a UUID"}},
+		},
+		"concept ID which is not a URI": {
+			annotations: []Annotation{{Predicate: "http://www.ft.com/ontology/annotation/about", ConceptID: "d7de27f8-1633-3fcc-b308-c95a2ad7d1cd"}},
+			expected:    []FieldError{{Field: "annotations[0].id", Value: "d7de27f8-1633-3fcc-b308-c95a2ad7d1cd", Message: "is not a concept URI ending in a UUID"}},
+		},
+		"unknown type": {
+			annotations: []Annotation{{Predicate: "http://www.ft.com/ontology/annotation/about", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd", Type: "SPACESHIP"}},
+			expected:    []FieldError{{Field: "annotations[0].type", Value: "SPACESHIP", Message: "is not a known concept type"}},
+		},
+		"duplicate annotations": {
+			annotations: []Annotation{
+				{Predicate: "http://www.ft.com/ontology/annotation/about", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd"},
+				{Predicate: "http://www.ft.com/ontology/annotation/mentions", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd"},
+				{Predicate: "http://www.ft.com/ontology/annotation/about", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd", Type: "TOPIC"},
+			},
+			expected: []FieldError{{Field: "annotations[2]", Message: "has the same predicate and id as annotations[0]"}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, v.Validate(AnnotationsBody{Annotations: test.annotations}))
+		})
+	}
+}
+
+func TestSchemaValidatorWithoutAllowLists(t *testing.T) {
+	v := NewSchemaValidator(nil, nil)
+	body := AnnotationsBody{Annotations: []Annotation{{Predicate: "anything", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd", Type: "ANYTHING"}}}
+
+	assert.Empty(t, v.Validate(body))
+}
+
+func TestDefaultsAcceptDocumentedExamples(t *testing.T) {
+	spec, err := os.ReadFile("../api/api.yml")
+	require.NoError(t, err)
+
+	var doc interface{}
+	require.NoError(t, yaml.Unmarshal(spec, &doc))
+
+	examples := annotationsExamples(doc)
+	require.NotEmpty(t, examples)
+
+	v := NewSchemaValidator(DefaultPredicates, DefaultConceptTypes)
+	for _, example := range examples {
+		raw, err := json.Marshal(example)
+		require.NoError(t, err)
+
+		var body AnnotationsBody
+		require.NoError(t, json.Unmarshal(raw, &body))
+		assert.Empty(t, v.Validate(body), string(raw))
+	}
+}
+
+// annotationsExamples finds every example of an annotations body in the API documentation
+func annotationsExamples(node interface{}) []interface{} {
+	var examples []interface{}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			if example, ok := value.(map[string]interface{}); ok && key == "example" && example["annotations"] != nil {
+				examples = append(examples, example)
+				continue
+			}
+			examples = append(examples, annotationsExamples(value)...)
+		}
+	case []interface{}:
+		for _, value := range n {
+			examples = append(examples, annotationsExamples(value)...)
+		}
+	}
+	return examples
+}
+
+func TestValidationError(t *testing.T) {
+	err := &ValidationError{Errors: []FieldError{{Field: "annotations[0].id", Message: "is required"}, {Field: "annotations[1]", Message: "is wrong"}}}
+
+	assert.True(t, errors.Is(err, ErrInvalidAnnotations))
+	assert.EqualError(t, err, "annotations are invalid: annotations[0].id is required; annotations[1] is wrong")
+}
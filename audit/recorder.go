@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/pborman/uuid"
+)
+
+// Recorder keeps the publish history in a Store. It implements annotations.PublishAuditor.
+type Recorder struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewRecorder returns a Recorder which adds the publish attempts to the given store
+func NewRecorder(store Store) *Recorder {
+	return &Recorder{store: store, now: time.Now}
+}
+
+// Record adds the publish attempt to the publish history of its content
+func (r *Recorder) Record(ctx context.Context, attempt annotations.PublishAttempt) error {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+
+	record := Record{
+		ID:             uuid.New(),
+		UUID:           attempt.UUID,
+		TransactionID:  txid,
+		OriginSystemID: attempt.OriginSystemID,
+		Editor:         attempt.Editor,
+		FromStore:      attempt.FromStore,
+		Unpublish:      attempt.Unpublish,
+		Republish:      attempt.Republish,
+		PreviousHash:   attempt.PreviousHash,
+		NewHash:        attempt.NewHash,
+		Outcome:        OutcomeSucceeded,
+		LatencyMillis:  attempt.Latency.Milliseconds(),
+		Time:           r.now().UTC(),
+	}
+	if attempt.Err != nil {
+		record.Outcome = OutcomeFailed
+		record.Error = attempt.Err.Error()
+	}
+	if attempt.Published != nil {
+		var previous annotations.AnnotationsBody
+		if attempt.Previous != nil {
+			previous = *attempt.Previous
+		}
+		diff := annotations.Diff(previous, *attempt.Published)
+		record.Diff = &diff
+	}
+
+	return r.store.Add(record)
+}
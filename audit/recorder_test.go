@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	store := NewMemoryStore(10, 0)
+	recorder := NewRecorder(store)
+	now := time.Now().UTC()
+	recorder.now = func() time.Time { return now }
+
+	previous := annotations.AnnotationsBody{Annotations: []annotations.Annotation{{Predicate: "about", ConceptID: "a"}, {Predicate: "mentions", ConceptID: "b"}}}
+	published := annotations.AnnotationsBody{Annotations: []annotations.Annotation{{Predicate: "about", ConceptID: "a"}, {Predicate: "hasAuthor", ConceptID: "c"}}}
+
+	ctx := tid.TransactionAwareContext(context.Background(), "tid_test")
+	err := recorder.Record(ctx, annotations.PublishAttempt{
+		UUID:           "a-valid-uuid",
+		OriginSystemID: "http://cmdb.ft.com/systems/pac",
+		PreviousHash:   "old-hash",
+		NewHash:        "new-hash",
+		Previous:       &previous,
+		Published:      &published,
+		Latency:        1500 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	err = recorder.Record(ctx, annotations.PublishAttempt{UUID: "a-valid-uuid", FromStore: true, Err: errors.New("eek")})
+	require.NoError(t, err)
+
+	records, err := store.List("a-valid-uuid")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	failed := records[0]
+	assert.Equal(t, OutcomeFailed, failed.Outcome)
+	assert.Equal(t, "eek", failed.Error)
+	assert.True(t, failed.FromStore)
+	assert.Nil(t, failed.Diff)
+
+	succeeded := records[1]
+	assert.NotEmpty(t, succeeded.ID)
+	assert.Equal(t, "tid_test", succeeded.TransactionID)
+	assert.Equal(t, "http://cmdb.ft.com/systems/pac", succeeded.OriginSystemID)
+	assert.Equal(t, OutcomeSucceeded, succeeded.Outcome)
+	assert.Equal(t, "old-hash", succeeded.PreviousHash)
+	assert.Equal(t, "new-hash", succeeded.NewHash)
+	assert.Equal(t, int64(1500), succeeded.LatencyMillis)
+	assert.Equal(t, now, succeeded.Time)
+	require.NotNil(t, succeeded.Diff)
+	assert.Equal(t, []annotations.Annotation{{Predicate: "hasAuthor", ConceptID: "c"}}, succeeded.Diff.Added)
+	assert.Equal(t, []annotations.Annotation{{Predicate: "mentions", ConceptID: "b"}}, succeeded.Diff.Removed)
+}
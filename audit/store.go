@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+)
+
+// Outcome is the result of a publish attempt
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	OutcomeFailed    Outcome = "failed"
+)
+
+// Record is an entry in the publish history of a piece of content
+type Record struct {
+	ID             string  `json:"id"`
+	UUID           string  `json:"uuid"`
+	TransactionID  string  `json:"transactionId"`
+	OriginSystemID string  `json:"originSystemId"`
+	Editor         string  `json:"editor,omitempty"`
+	FromStore      bool    `json:"fromStore"`
+	Unpublish      bool    `json:"unpublish,omitempty"`
+	Republish      bool    `json:"republish,omitempty"`
+	PreviousHash   string  `json:"previousHash,omitempty"`
+	NewHash        string  `json:"newHash,omitempty"`
+	Outcome        Outcome `json:"outcome"`
+	Error          string  `json:"error,omitempty"`
+	// Diff holds the changes to the published annotations, and is nil if the attempt failed before they were written
+	Diff          *annotations.AnnotationsDiff `json:"diff,omitempty"`
+	LatencyMillis int64                        `json:"latencyMs"`
+	Time          time.Time                    `json:"time"`
+}
+
+// Store persists the publish history
+type Store interface {
+	Add(record Record) error
+	// List returns the publish history of the content, most recent first
+	List(uuid string) ([]Record, error)
+}
+
+type memoryStore struct {
+	maxRecords int
+	maxContent int
+
+	mutex   sync.RWMutex
+	records map[string]*list.Element
+	// recent orders the content by its latest record, most recent first, so the least recently published content is dropped first
+	recent *list.List
+}
+
+type contentRecords struct {
+	uuid    string
+	records []Record
+}
+
+// NewMemoryStore returns a Store which keeps at most maxRecords of the most recent records of every piece of content in memory,
+// for at most maxContent of the most recently published pieces of content. Zero keeps every record or every piece of content.
+func NewMemoryStore(maxRecords int, maxContent int) Store {
+	return &memoryStore{maxRecords: maxRecords, maxContent: maxContent, records: make(map[string]*list.Element), recent: list.New()}
+}
+
+func (s *memoryStore) Add(record Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	el, ok := s.records[record.UUID]
+	if ok {
+		s.recent.MoveToFront(el)
+	} else {
+		el = s.recent.PushFront(&contentRecords{uuid: record.UUID})
+		s.records[record.UUID] = el
+	}
+
+	content := el.Value.(*contentRecords)
+	content.records = append(content.records, record)
+	if s.maxRecords > 0 && len(content.records) > s.maxRecords {
+		content.records = content.records[len(content.records)-s.maxRecords:]
+	}
+
+	for s.maxContent > 0 && s.recent.Len() > s.maxContent {
+		oldest := s.recent.Remove(s.recent.Back()).(*contentRecords)
+		delete(s.records, oldest.uuid)
+	}
+	return nil
+}
+
+func (s *memoryStore) List(uuid string) ([]Record, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	el, ok := s.records[uuid]
+	if !ok {
+		return newestFirst(nil), nil
+	}
+	return newestFirst(el.Value.(*contentRecords).records), nil
+}
+
+type fileStore struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// NewFileStore returns a Store which appends the records of every piece of content as JSON lines to a file in the given directory
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) Add(record Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path(record.UUID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *fileStore) List(uuid string) ([]Record, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, err := os.Open(s.path(uuid))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Record{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return newestFirst(records), nil
+}
+
+func (s *fileStore) path(uuid string) string {
+	// uuids come from the request path, so never let one escape the store directory
+	return filepath.Join(s.dir, strings.ReplaceAll(filepath.Base(uuid), ".", "_")+".jsonl")
+}
+
+func newestFirst(records []Record) []Record {
+	reversed := make([]Record, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		reversed = append(reversed, records[i])
+	}
+	return reversed
+}
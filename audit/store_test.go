@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	stores := map[string]Store{
+		"memory": NewMemoryStore(10, 0),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now().UTC().Truncate(time.Second)
+			diff := &annotations.AnnotationsDiff{
+				Added:   []annotations.Annotation{{Predicate: "foo", ConceptID: "bar"}},
+				Removed: []annotations.Annotation{},
+				Changed: []annotations.AnnotationChange{},
+			}
+			first := Record{ID: "first", UUID: "a-valid-uuid", TransactionID: "tid_test", Outcome: OutcomeSucceeded, NewHash: "hash", Diff: diff, Time: now.Add(-time.Minute)}
+			second := Record{ID: "second", UUID: "a-valid-uuid", TransactionID: "tid_test", Outcome: OutcomeFailed, Error: "eek", Time: now}
+			other := Record{ID: "other", UUID: "another-valid-uuid", Outcome: OutcomeSucceeded, Time: now}
+
+			require.NoError(t, store.Add(first))
+			require.NoError(t, store.Add(other))
+			require.NoError(t, store.Add(second))
+
+			records, err := store.List("a-valid-uuid")
+			require.NoError(t, err)
+			assert.Equal(t, []Record{second, first}, records)
+
+			records, err = store.List("unknown-uuid")
+			require.NoError(t, err)
+			assert.Empty(t, records)
+		})
+	}
+}
+
+func TestMemoryStoreDropsOldestRecords(t *testing.T) {
+	store := NewMemoryStore(2, 0)
+	for _, id := range []string{"first", "second", "third"} {
+		require.NoError(t, store.Add(Record{ID: id, UUID: "a-valid-uuid"}))
+	}
+
+	records, err := store.List("a-valid-uuid")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "third", records[0].ID)
+	assert.Equal(t, "second", records[1].ID)
+}
+
+func TestMemoryStoreDropsLeastRecentlyPublishedContent(t *testing.T) {
+	store := NewMemoryStore(10, 2)
+	for _, uuid := range []string{"first-uuid", "second-uuid", "first-uuid", "third-uuid"} {
+		require.NoError(t, store.Add(Record{ID: "an-id", UUID: uuid}))
+	}
+
+	records, err := store.List("second-uuid")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	records, err = store.List("first-uuid")
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	records, err = store.List("third-uuid")
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestFileStoreIsDurable(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	record := Record{ID: "an-id", UUID: "a-valid-uuid", Outcome: OutcomeSucceeded, Time: time.Now().UTC().Truncate(time.Second)}
+	require.NoError(t, store.Add(record))
+
+	reopened, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	records, err := reopened.List("a-valid-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, []Record{record}, records)
+}
+
+func TestFileStoreDoesNotEscapeDirectory(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	records, err := store.List("../../etc/passwd")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNoCredentials occurs when a request has none of the credentials checked by an Authenticator
+	ErrNoCredentials = errors.New("request has no credentials")
+	// ErrInvalidCredentials occurs when the credentials of a request are unknown, expired or wrongly signed
+	ErrInvalidCredentials = errors.New("request credentials are invalid")
+)
+
+// Scope is a group of endpoints an identity is allowed to call
+type Scope string
+
+const (
+	// ScopePublish allows annotations to be saved, published and unpublished
+	ScopePublish Scope = "publish"
+	// ScopeFromStore allows the draft annotations to be published as they are stored, with fromStore=true
+	ScopeFromStore Scope = "fromStore"
+	// ScopeBatch allows batch publishes
+	ScopeBatch Scope = "batch"
+	// ScopeAdmin allows republishes and the management of failed publishes, and grants every other scope
+	ScopeAdmin Scope = "admin"
+)
+
+var knownScopes = map[Scope]bool{ScopePublish: true, ScopeFromStore: true, ScopeBatch: true, ScopeAdmin: true}
+
+// ParseScopes returns the scopes with the given names, or an error if any of them is unknown
+func ParseScopes(names []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(names))
+	for _, name := range names {
+		if !knownScopes[Scope(name)] {
+			return nil, fmt.Errorf("unknown scope %v", name)
+		}
+		scopes = append(scopes, Scope(name))
+	}
+	return scopes, nil
+}
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	// Client is the ID of the API key or HMAC secret of the caller, or the subject of its JWT
+	Client string
+	// User is the subject of a JWT, and is empty for API keys and HMAC signatures, which identify systems rather than users
+	User   string
+	Scopes []Scope
+}
+
+// HasScope reports whether the identity holds the scope, or the admin scope
+func (i Identity) HasScope(scope Scope) bool {
+	for _, s := range i.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator identifies the caller of a request from its credentials
+type Authenticator interface {
+	// Authenticate returns the identity of the caller. It returns ErrNoCredentials if the request has none of the credentials it checks,
+	// and an error which is ErrInvalidCredentials if they are not valid.
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+// Chain returns an Authenticator which authenticates a request with the first of the authenticators whose credentials the request has
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+type chain []Authenticator
+
+func (c chain) Authenticate(r *http.Request) (Identity, error) {
+	for _, a := range c {
+		identity, err := a.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return identity, err
+		}
+	}
+	return Identity{}, ErrNoCredentials
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx holding the authenticated caller of the request
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the authenticated caller held by ctx, if there is one
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasScope(t *testing.T) {
+	publisher := Identity{Client: "pac-ui", Scopes: []Scope{ScopePublish}}
+	assert.True(t, publisher.HasScope(ScopePublish))
+	assert.False(t, publisher.HasScope(ScopeFromStore))
+
+	admin := Identity{Client: "ops", Scopes: []Scope{ScopeAdmin}}
+	assert.True(t, admin.HasScope(ScopeBatch))
+	assert.True(t, admin.HasScope(ScopeFromStore))
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes([]string{"publish", "batch"})
+	require.NoError(t, err)
+	assert.Equal(t, []Scope{ScopePublish, ScopeBatch}, scopes)
+
+	_, err = ParseScopes([]string{"publish", "Admin"})
+	assert.EqualError(t, err, "unknown scope Admin")
+}
+
+type staticAuthenticator struct {
+	identity Identity
+	err      error
+}
+
+func (a staticAuthenticator) Authenticate(*http.Request) (Identity, error) {
+	return a.identity, a.err
+}
+
+func TestChain(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	identity, err := Chain(staticAuthenticator{err: ErrNoCredentials}, staticAuthenticator{identity: Identity{Client: "pac-ui"}}).Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "pac-ui", identity.Client)
+
+	_, err = Chain(staticAuthenticator{err: ErrInvalidCredentials}, staticAuthenticator{identity: Identity{Client: "pac-ui"}}).Authenticate(req)
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "invalid credentials should not be ignored")
+
+	_, err = Chain(staticAuthenticator{err: ErrNoCredentials}).Authenticate(req)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestIdentityFromContext(t *testing.T) {
+	_, ok := IdentityFromContext(context.Background())
+	assert.False(t, ok)
+
+	identity, ok := IdentityFromContext(WithIdentity(context.Background(), Identity{Client: "pac-ui"}))
+	assert.True(t, ok)
+	assert.Equal(t, "pac-ui", identity.Client)
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// APIKeyHeader holds the API key of the caller
+	APIKeyHeader = "X-Api-Key"
+	// HMACScheme is the Authorization scheme of HMAC signed requests, whose credentials are {client id}:{base64 signature}
+	HMACScheme = "HMAC-SHA256"
+	// TimestampHeader holds the Unix time an HMAC signed request was signed at
+	TimestampHeader = "X-Signature-Timestamp"
+)
+
+// Client is a system which is allowed to call the service with an API key or HMAC signed requests
+type Client struct {
+	ID string `yaml:"id"`
+	// APIKey is empty if the client cannot use an API key
+	APIKey string `yaml:"apiKey"`
+	// HMACSecret is empty if the client cannot sign requests
+	HMACSecret string   `yaml:"hmacSecret"`
+	Scopes     []string `yaml:"scopes"`
+}
+
+type clientsFile struct {
+	Clients []Client `yaml:"clients"`
+}
+
+// LoadClients reads the clients in the given YAML file, checking that every client has an ID and only known scopes
+func LoadClients(path string) ([]Client, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var file clientsFile
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to read clients from %v: %w", path, err)
+	}
+	for i, c := range file.Clients {
+		if c.ID == "" {
+			return nil, fmt.Errorf("client %v in %v has no id", i, path)
+		}
+		if _, err := ParseScopes(c.Scopes); err != nil {
+			return nil, fmt.Errorf("client %v: %w", c.ID, err)
+		}
+	}
+	return file.Clients, nil
+}
+
+func (c Client) identity() Identity {
+	// the scopes were checked when the clients were loaded
+	scopes, _ := ParseScopes(c.Scopes)
+	return Identity{Client: c.ID, Scopes: scopes}
+}
+
+// NewAPIKeyAuthenticator returns an Authenticator which identifies callers by the API key in their X-Api-Key header
+func NewAPIKeyAuthenticator(clients []Client) Authenticator {
+	// keys are looked up by their hash, so the time taken does not reveal how much of a key is correct
+	keys := make(map[string]Client)
+	for _, c := range clients {
+		if c.APIKey != "" {
+			keys[hashKey(c.APIKey)] = c
+		}
+	}
+	return &apiKeyAuthenticator{keys: keys}
+}
+
+type apiKeyAuthenticator struct {
+	keys map[string]Client
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (Identity, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return Identity{}, ErrNoCredentials
+	}
+	c, ok := a.keys[hashKey(key)]
+	if !ok {
+		return Identity{}, ErrInvalidCredentials
+	}
+	return c.identity(), nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewHMACAuthenticator returns an Authenticator for requests signed with the HMAC secret of a client.
+// Requests signed more than maxSkew before or after they are received are rejected.
+func NewHMACAuthenticator(clients []Client, maxSkew time.Duration) Authenticator {
+	secrets := make(map[string]Client)
+	for _, c := range clients {
+		if c.HMACSecret != "" {
+			secrets[c.ID] = c
+		}
+	}
+	return &hmacAuthenticator{secrets: secrets, maxSkew: maxSkew, now: time.Now}
+}
+
+type hmacAuthenticator struct {
+	secrets map[string]Client
+	maxSkew time.Duration
+	now     func() time.Time
+}
+
+func (a *hmacAuthenticator) Authenticate(r *http.Request) (Identity, error) {
+	credentials, ok := strings.CutPrefix(r.Header.Get("Authorization"), HMACScheme+" ")
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+	id, encoded, ok := strings.Cut(credentials, ":")
+	if !ok {
+		return Identity{}, fmt.Errorf("%w: malformed signature", ErrInvalidCredentials)
+	}
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: malformed signature", ErrInvalidCredentials)
+	}
+	c, ok := a.secrets[id]
+	if !ok {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	timestamp := r.Header.Get(TimestampHeader)
+	signed, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: missing or malformed %v", ErrInvalidCredentials, TimestampHeader)
+	}
+	if skew := a.now().Sub(time.Unix(signed, 0)); math.Abs(float64(skew)) > float64(a.maxSkew) {
+		return Identity{}, fmt.Errorf("%w: signature has expired", ErrInvalidCredentials)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return Identity{}, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if !hmac.Equal(signature, Sign(c.HMACSecret, r.Method, r.URL.RequestURI(), timestamp, body)) {
+		return Identity{}, fmt.Errorf("%w: signature does not match", ErrInvalidCredentials)
+	}
+	return c.identity(), nil
+}
+
+// Sign returns the HMAC-SHA256 signature of a request, over its method, path and query, timestamp and the SHA-256 hash of its body, each on its own line
+func Sign(secret string, method string, requestURI string, timestamp string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])))
+	return mac.Sum(nil)
+}
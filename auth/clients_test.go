@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testClients = `clients:
+  - id: pac-ui
+    apiKey: a-key
+    hmacSecret: a-secret
+    scopes: [publish, fromStore]
+  - id: ops
+    apiKey: an-admin-key
+    scopes: [admin]
+`
+
+func writeClients(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "clients.yml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadClients(t *testing.T) {
+	clients, err := LoadClients(writeClients(t, testClients))
+	require.NoError(t, err)
+	require.Len(t, clients, 2)
+	assert.Equal(t, Client{ID: "pac-ui", APIKey: "a-key", HMACSecret: "a-secret", Scopes: []string{"publish", "fromStore"}}, clients[0])
+}
+
+func TestLoadClientsInvalid(t *testing.T) {
+	tests := map[string]struct {
+		content     string
+		expectedErr string
+	}{
+		"unknown scope": {
+			content:     "clients:\n  - id: pac-ui\n    scopes: [everything]\n",
+			expectedErr: "client pac-ui: unknown scope everything",
+		},
+		"missing id": {
+			content:     "clients:\n  - apiKey: a-key\n",
+			expectedErr: "has no id",
+		},
+		"unknown field": {
+			content:     "clients:\n  - id: pac-ui\n    password: secret\n",
+			expectedErr: "field password not found",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadClients(writeClients(t, test.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	clients, err := LoadClients(writeClients(t, testClients))
+	require.NoError(t, err)
+	a := NewAPIKeyAuthenticator(clients)
+
+	req := httptest.NewRequest(http.MethodPost, "/drafts/content/a-valid-uuid/annotations/publish", nil)
+	_, err = a.Authenticate(req)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+
+	req.Header.Set(APIKeyHeader, "a-key")
+	identity, err := a.Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, Identity{Client: "pac-ui", Scopes: []Scope{ScopePublish, ScopeFromStore}}, identity)
+
+	req.Header.Set(APIKeyHeader, "another-key")
+	_, err = a.Authenticate(req)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	clients, err := LoadClients(writeClients(t, testClients))
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	a := NewHMACAuthenticator(clients, 5*time.Minute).(*hmacAuthenticator)
+	a.now = func() time.Time { return now }
+
+	signedRequest := func(id string, secret string, signedAt time.Time, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/drafts/content/a-valid-uuid/annotations/publish?fromStore=false", strings.NewReader(body))
+		timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+		signature := Sign(secret, http.MethodPost, "/drafts/content/a-valid-uuid/annotations/publish?fromStore=false", timestamp, []byte(body))
+		req.Header.Set("Authorization", HMACScheme+" "+id+":"+base64.StdEncoding.EncodeToString(signature))
+		req.Header.Set(TimestampHeader, timestamp)
+		return req
+	}
+
+	req := signedRequest("pac-ui", "a-secret", now.Add(-time.Minute), `{"annotations":[]}`)
+	identity, err := a.Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "pac-ui", identity.Client)
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"annotations":[]}`, string(body), "the body should still be readable")
+
+	tests := map[string]*http.Request{
+		"wrong secret":          signedRequest("pac-ui", "another-secret", now, "{}"),
+		"unknown client":        signedRequest("someone", "a-secret", now, "{}"),
+		"client without secret": signedRequest("ops", "", now, "{}"),
+		"expired signature":     signedRequest("pac-ui", "a-secret", now.Add(-10*time.Minute), "{}"),
+	}
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := a.Authenticate(req)
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		})
+	}
+
+	t.Run("changed body", func(t *testing.T) {
+		req := signedRequest("pac-ui", "a-secret", now, "{}")
+		req.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"annotations":[]}`)).Body
+		_, err := a.Authenticate(req)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("no signature", func(t *testing.T) {
+		_, err := a.Authenticate(httptest.NewRequest(http.MethodPost, "/", nil))
+		assert.ErrorIs(t, err, ErrNoCredentials)
+	})
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// BearerScheme is the Authorization scheme of requests with a JWT
+const BearerScheme = "Bearer"
+
+// jsonWebKey holds the fields of the RSA and EC keys of a JWKS
+type jsonWebKey struct {
+	KeyID string `json:"kid"`
+	Type  string `json:"kty"`
+	Use   string `json:"use"`
+	N     string `json:"n"`
+	E     string `json:"e"`
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	// Scope holds the scopes of the caller, separated by spaces
+	Scope string `json:"scope"`
+}
+
+// JWTAuthenticator identifies callers by the subject and scope claims of the JWT in their Authorization header, which must be signed by one of the keys of a JWKS
+type JWTAuthenticator struct {
+	keys   map[string]interface{}
+	parser *jwt.Parser
+}
+
+// LoadJWTAuthenticator returns a JWTAuthenticator which verifies JWTs with the RSA and EC keys in the given JWKS file.
+// The issuer and audience of JWTs are not checked if empty.
+func LoadJWTAuthenticator(jwksPath string, issuer string, audience string) (*JWTAuthenticator, error) {
+	data, err := os.ReadFile(jwksPath)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to read JWKS from %v: %w", jwksPath, err)
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %v of %v: %w", k.KeyID, jwksPath, err)
+		}
+		keys[k.KeyID] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%v has no signing keys", jwksPath)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	return &JWTAuthenticator{keys: keys, parser: jwt.NewParser(opts...)}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), BearerScheme+" ")
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+
+	var claims jwtClaims
+	if _, err := a.parser.ParseWithClaims(token, &claims, a.key); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if claims.Subject == "" {
+		return Identity{}, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	identity := Identity{Client: claims.Subject, User: claims.Subject}
+	for _, name := range strings.Fields(claims.Scope) {
+		// scopes of other services may be granted by the same token
+		if knownScopes[Scope(name)] {
+			identity.Scopes = append(identity.Scopes, Scope(name))
+		}
+	}
+	return identity, nil
+}
+
+func (a *JWTAuthenticator) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := a.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Type {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %v", k.Curve)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %v", k.Type)
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
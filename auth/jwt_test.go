@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{"kid": "rsa-key", "kty": "RSA", "use": "sig", "n": encodeInt(rsaKey.N), "e": encodeInt(big.NewInt(int64(rsaKey.E)))},
+			{"kid": "ec-key", "kty": "EC", "crv": "P-256", "x": encodeInt(ecKey.X), "y": encodeInt(ecKey.Y)},
+		},
+	}
+	data, err := json.Marshal(jwks)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	a, err := LoadJWTAuthenticator(writeJWKS(t, rsaKey, ecKey), "https://sso.example.com", "annotations-publisher")
+	require.NoError(t, err)
+
+	claims := func(modify func(c jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub":   "jane.doe",
+			"iss":   "https://sso.example.com",
+			"aud":   "annotations-publisher",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "openid publish fromStore",
+		}
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, c jwt.MapClaims) *http.Request {
+		token := jwt.NewWithClaims(method, c)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/drafts/content/a-valid-uuid/annotations/publish", nil)
+		req.Header.Set("Authorization", BearerScheme+" "+signed)
+		return req
+	}
+
+	identity, err := a.Authenticate(sign(jwt.SigningMethodRS256, "rsa-key", rsaKey, claims(nil)))
+	require.NoError(t, err)
+	assert.Equal(t, Identity{Client: "jane.doe", User: "jane.doe", Scopes: []Scope{ScopePublish, ScopeFromStore}}, identity)
+
+	identity, err = a.Authenticate(sign(jwt.SigningMethodES256, "ec-key", ecKey, claims(nil)))
+	require.NoError(t, err)
+	assert.Equal(t, "jane.doe", identity.User)
+
+	tests := map[string]*http.Request{
+		"expired":         sign(jwt.SigningMethodRS256, "rsa-key", rsaKey, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })),
+		"without expiry":  sign(jwt.SigningMethodRS256, "rsa-key", rsaKey, claims(func(c jwt.MapClaims) { delete(c, "exp") })),
+		"wrong issuer":    sign(jwt.SigningMethodRS256, "rsa-key", rsaKey, claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" })),
+		"wrong audience":  sign(jwt.SigningMethodRS256, "rsa-key", rsaKey, claims(func(c jwt.MapClaims) { c["aud"] = "another-service" })),
+		"without subject": sign(jwt.SigningMethodRS256, "rsa-key", rsaKey, claims(func(c jwt.MapClaims) { delete(c, "sub") })),
+		"unknown key":     sign(jwt.SigningMethodRS256, "another-key", rsaKey, claims(nil)),
+		"wrong key":       sign(jwt.SigningMethodRS256, "rsa-key", otherKey, claims(nil)),
+		"symmetric":       sign(jwt.SigningMethodHS256, "rsa-key", []byte("a-secret"), claims(nil)),
+	}
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := a.Authenticate(req)
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		})
+	}
+
+	_, err = a.Authenticate(httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestLoadJWTAuthenticatorWithoutKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys":[{"kid":"enc","kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"}]}`), 0600))
+
+	_, err := LoadJWTAuthenticator(path, "", "")
+	assert.EqualError(t, err, path+" has no signing keys")
+}
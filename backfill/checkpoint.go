@@ -0,0 +1,83 @@
+package backfill
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Checkpoint records the UUIDs which have been published in a file, one per line, so they are skipped when a backfill is resumed
+type Checkpoint struct {
+	mutex sync.Mutex
+	file  *os.File
+	done  map[string]bool
+}
+
+// OpenCheckpoint reads the UUIDs recorded in the file at path, creating it if it does not exist. A nil Checkpoint is returned if path is empty,
+// which records nothing.
+func OpenCheckpoint(path string) (*Checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	done := make(map[string]bool)
+	existing, err := os.Open(path)
+	if err == nil {
+		scanner := bufio.NewScanner(existing)
+		for scanner.Scan() {
+			if uuid := strings.TrimSpace(scanner.Text()); uuid != "" {
+				done[uuid] = true
+			}
+		}
+		existing.Close()
+		if err = scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint %v: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoint{file: file, done: done}, nil
+}
+
+// Done reports whether the UUID has already been published
+func (c *Checkpoint) Done(uuid string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.done[uuid]
+}
+
+// Add records that the UUID has been published
+func (c *Checkpoint) Add(uuid string) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, err := c.file.WriteString(uuid + "\n"); err != nil {
+		return err
+	}
+	c.done[uuid] = true
+	return nil
+}
+
+// Close closes the checkpoint file
+func (c *Checkpoint) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.file.Close()
+}
@@ -0,0 +1,120 @@
+package backfill
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcome is the result of publishing a single piece of content
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	OutcomeFailed    Outcome = "failed"
+)
+
+// Result is the outcome of publishing a single piece of content, as written to the report
+type Result struct {
+	UUID          string    `json:"uuid"`
+	TransactionID string    `json:"transactionId"`
+	Outcome       Outcome   `json:"outcome"`
+	DocumentHash  string    `json:"documentHash,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	LatencyMillis int64     `json:"latencyMs"`
+	Time          time.Time `json:"time"`
+}
+
+// Report writes the result of every publish of a backfill
+type Report interface {
+	Write(result Result) error
+}
+
+// FileReport is a Report appended to a file
+type FileReport struct {
+	Report
+	file *os.File
+}
+
+// OpenReport returns a Report which appends to the file at path, creating it if it does not exist.
+// The report is written as CSV if path ends in .csv, with a header if the file is empty, and as JSON lines otherwise.
+func OpenReport(path string) (*FileReport, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(strings.ToLower(path), ".csv") {
+		return &FileReport{Report: newJSONReport(file), file: file}, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	report, err := newCSVReport(file, info.Size() == 0)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &FileReport{Report: report, file: file}, nil
+}
+
+// Close closes the report file
+func (r *FileReport) Close() error {
+	return r.file.Close()
+}
+
+type jsonReport struct {
+	enc *json.Encoder
+}
+
+func newJSONReport(w io.Writer) *jsonReport {
+	return &jsonReport{enc: json.NewEncoder(w)}
+}
+
+func (r *jsonReport) Write(result Result) error {
+	return r.enc.Encode(result)
+}
+
+type csvReport struct {
+	w *csv.Writer
+}
+
+var csvHeader = []string{"uuid", "transactionId", "outcome", "documentHash", "error", "latencyMs", "time"}
+
+func newCSVReport(w io.Writer, header bool) (*csvReport, error) {
+	r := &csvReport{w: csv.NewWriter(w)}
+	if header {
+		if err := r.write(csvHeader); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *csvReport) Write(result Result) error {
+	return r.write([]string{
+		result.UUID,
+		result.TransactionID,
+		string(result.Outcome),
+		result.DocumentHash,
+		result.Error,
+		strconv.FormatInt(result.LatencyMillis, 10),
+		result.Time.Format(time.RFC3339Nano),
+	})
+}
+
+// write writes and flushes a row, so the report is complete up to the last publish if the backfill is interrupted
+func (r *csvReport) write(row []string) error {
+	if err := r.w.Write(row); err != nil {
+		return err
+	}
+	r.w.Flush()
+	return r.w.Error()
+}
@@ -0,0 +1,63 @@
+package backfill
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenReport(t *testing.T) {
+	result := Result{
+		UUID:          "a-valid-uuid",
+		TransactionID: "tid_test",
+		Outcome:       OutcomeFailed,
+		Error:         "publish to upp returned a 500 status code",
+		LatencyMillis: 12,
+		Time:          time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	tests := map[string]struct {
+		file   string
+		header string
+		row    string
+	}{
+		"json lines": {
+			file: "report.jsonl",
+			row:  `{"uuid":"a-valid-uuid","transactionId":"tid_test","outcome":"failed","error":"publish to upp returned a 500 status code","latencyMs":12,"time":"2024-01-02T03:04:05Z"}` + "\n",
+		},
+		"csv": {
+			file:   "report.csv",
+			header: "uuid,transactionId,outcome,documentHash,error,latencyMs,time\n",
+			row:    "a-valid-uuid,tid_test,failed,,publish to upp returned a 500 status code,12,2024-01-02T03:04:05Z\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+
+			report, err := OpenReport(path)
+			require.NoError(t, err)
+			require.NoError(t, report.Write(result))
+			require.NoError(t, report.Close())
+
+			actual, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, test.header+test.row, string(actual))
+
+			// a resumed backfill appends to the report, without repeating the header
+			report, err = OpenReport(path)
+			require.NoError(t, err)
+			require.NoError(t, report.Write(result))
+			require.NoError(t, report.Close())
+
+			actual, err = os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, test.header+test.row+test.row, string(actual))
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package backfill
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+)
+
+// PublishFunc publishes the annotations of a single piece of content, returning the Document-Hash of the published annotations
+type PublishFunc func(ctx context.Context, uuid string) (string, error)
+
+// Options configures how fast a backfill runs
+type Options struct {
+	Concurrency int
+	// Rate is the maximum number of publishes started per second. There is no limit if zero.
+	Rate float64
+	// Timeout is the time allowed for every publish
+	Timeout time.Duration
+}
+
+// Summary counts the outcomes of a backfill
+type Summary struct {
+	Succeeded int
+	Failed    int
+	// Skipped are the UUIDs which had been published by an earlier run, according to the checkpoint
+	Skipped int
+}
+
+// Run publishes every UUID read from uuids, one per line, and reports the outcome of every publish.
+// UUIDs recorded in the checkpoint are skipped, and every successful publish is added to it, so an interrupted backfill can be resumed.
+// Cancelling ctx stops the backfill once the publishes in progress have finished.
+func Run(ctx context.Context, uuids io.Reader, publish PublishFunc, checkpoint *Checkpoint, report Report, opts Options, log *logger.UPPLogger) (Summary, error) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+
+	var summary Summary
+	var mutex sync.Mutex
+	var reportErr error
+	var wg sync.WaitGroup
+	pending := make(chan string)
+
+	limiter := newLimiter(opts.Rate)
+	defer limiter.stop()
+
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for uuid := range pending {
+				// a UUID handed over as the backfill was cancelled is left for the next run
+				if ctx.Err() != nil {
+					continue
+				}
+				result := publishOne(ctx, uuid, publish, opts.Timeout)
+
+				mutex.Lock()
+				if result.Outcome == OutcomeSucceeded {
+					summary.Succeeded++
+					if err := checkpoint.Add(uuid); err != nil && reportErr == nil {
+						reportErr = err
+					}
+				} else {
+					summary.Failed++
+					log.WithUUID(uuid).WithTransactionID(result.TransactionID).WithField("reason", result.Error).Warn("publish failed")
+				}
+				if err := report.Write(result); err != nil && reportErr == nil {
+					reportErr = err
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	scanner := bufio.NewScanner(uuids)
+	var readErr error
+feed:
+	for scanner.Scan() {
+		uuid := strings.TrimSpace(scanner.Text())
+		if uuid == "" || strings.HasPrefix(uuid, "#") {
+			continue
+		}
+		if checkpoint.Done(uuid) {
+			mutex.Lock()
+			summary.Skipped++
+			mutex.Unlock()
+			continue
+		}
+		if err := limiter.wait(ctx); err != nil || ctx.Err() != nil {
+			break feed
+		}
+
+		select {
+		case pending <- uuid:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	readErr = scanner.Err()
+	close(pending)
+	wg.Wait()
+
+	if readErr != nil {
+		return summary, readErr
+	}
+	if reportErr != nil {
+		return summary, reportErr
+	}
+	return summary, ctx.Err()
+}
+
+// publishOne publishes the UUID. Cancelling ctx does not cancel the publish, so a publish is never abandoned between the published store and UPP.
+func publishOne(ctx context.Context, uuid string, publish PublishFunc, timeout time.Duration) Result {
+	txid := tid.NewTransactionID()
+	ctx = tid.TransactionAwareContext(context.WithoutCancel(ctx), txid)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	hash, err := publish(ctx, uuid)
+	result := Result{
+		UUID:          uuid,
+		TransactionID: txid,
+		Outcome:       OutcomeSucceeded,
+		DocumentHash:  hash,
+		LatencyMillis: time.Since(start).Milliseconds(),
+		Time:          start.UTC(),
+	}
+	if err != nil {
+		result.Outcome = OutcomeFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// limiter spaces out the start of publishes so that at most rate are started every second
+type limiter struct {
+	ticker *time.Ticker
+}
+
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return &limiter{}
+	}
+	return &limiter{ticker: time.NewTicker(time.Duration(float64(time.Second) / rate))}
+}
+
+func (l *limiter) wait(ctx context.Context) error {
+	if l.ticker == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-l.ticker.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *limiter) stop() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+}
@@ -0,0 +1,137 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingReport struct {
+	mutex   sync.Mutex
+	results []Result
+}
+
+func (r *recordingReport) Write(result Result) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.results = append(r.results, result)
+	return nil
+}
+
+func (r *recordingReport) byUUID() map[string]Result {
+	results := make(map[string]Result)
+	for _, result := range r.results {
+		results[result.UUID] = result
+	}
+	return results
+}
+
+func TestRunPublishesEveryUUID(t *testing.T) {
+	var mutex sync.Mutex
+	published := make(map[string]string)
+	publish := func(ctx context.Context, uuid string) (string, error) {
+		txid, err := tid.GetTransactionIDFromContext(ctx)
+		require.NoError(t, err)
+		mutex.Lock()
+		defer mutex.Unlock()
+		published[uuid] = txid
+		if uuid == "failing-uuid" {
+			return "", errors.New("publish to upp returned a 500 status code")
+		}
+		return "hash-" + uuid, nil
+	}
+
+	checkpoint, err := OpenCheckpoint(filepath.Join(t.TempDir(), "checkpoint"))
+	require.NoError(t, err)
+	defer checkpoint.Close()
+
+	report := &recordingReport{}
+	input := "uuid-1\n\n# a comment\n failing-uuid \nuuid-2\n"
+	summary, err := Run(context.Background(), strings.NewReader(input), publish, checkpoint, report, Options{Concurrency: 2}, logger.NewUPPLogger("test", "DEBUG"))
+	require.NoError(t, err)
+
+	assert.Equal(t, Summary{Succeeded: 2, Failed: 1}, summary)
+	assert.Len(t, published, 3)
+
+	results := report.byUUID()
+	require.Len(t, results, 3)
+	assert.Equal(t, OutcomeSucceeded, results["uuid-1"].Outcome)
+	assert.Equal(t, "hash-uuid-1", results["uuid-1"].DocumentHash)
+	assert.Equal(t, published["uuid-1"], results["uuid-1"].TransactionID)
+	assert.Equal(t, OutcomeFailed, results["failing-uuid"].Outcome)
+	assert.Equal(t, "publish to upp returned a 500 status code", results["failing-uuid"].Error)
+
+	assert.True(t, checkpoint.Done("uuid-1"))
+	assert.True(t, checkpoint.Done("uuid-2"))
+	assert.False(t, checkpoint.Done("failing-uuid"))
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	checkpoint, err := OpenCheckpoint(path)
+	require.NoError(t, err)
+	require.NoError(t, checkpoint.Add("uuid-1"))
+	require.NoError(t, checkpoint.Close())
+
+	checkpoint, err = OpenCheckpoint(path)
+	require.NoError(t, err)
+	defer checkpoint.Close()
+
+	var published []string
+	publish := func(ctx context.Context, uuid string) (string, error) {
+		published = append(published, uuid)
+		return "hash", nil
+	}
+
+	summary, err := Run(context.Background(), strings.NewReader("uuid-1\nuuid-2\n"), publish, checkpoint, &recordingReport{}, Options{}, logger.NewUPPLogger("test", "DEBUG"))
+	require.NoError(t, err)
+
+	assert.Equal(t, Summary{Succeeded: 1, Skipped: 1}, summary)
+	assert.Equal(t, []string{"uuid-2"}, published)
+}
+
+func TestRunWithoutCheckpoint(t *testing.T) {
+	publish := func(ctx context.Context, uuid string) (string, error) {
+		return "hash", nil
+	}
+
+	summary, err := Run(context.Background(), strings.NewReader("uuid-1\nuuid-1\n"), publish, nil, &recordingReport{}, Options{}, logger.NewUPPLogger("test", "DEBUG"))
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Succeeded: 2}, summary)
+}
+
+func TestRunStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var published []string
+	var publishErr error
+	publish := func(ctx context.Context, uuid string) (string, error) {
+		published = append(published, uuid)
+		cancel()
+		// the publish in progress is not cancelled with the backfill
+		select {
+		case <-ctx.Done():
+			publishErr = ctx.Err()
+			return "", ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+			return "hash", nil
+		}
+	}
+
+	report := &recordingReport{}
+	summary, err := Run(ctx, strings.NewReader("uuid-1\nuuid-2\nuuid-3\n"), publish, nil, report, Options{Rate: 1000, Timeout: time.Second}, logger.NewUPPLogger("test", "DEBUG"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoError(t, publishErr)
+	assert.Equal(t, []string{"uuid-1"}, published)
+	assert.Equal(t, Summary{Succeeded: 1}, summary)
+	require.Len(t, report.results, 1)
+	assert.Equal(t, OutcomeSucceeded, report.results[0].Outcome)
+}
@@ -0,0 +1,179 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrOpen occurs when a request is rejected because the circuit breaker of the downstream service is open
+var ErrOpen = errors.New("circuit breaker is open")
+
+// OpenError is returned for requests rejected by an open circuit breaker, and reports when the breaker will let requests through again
+type OpenError struct {
+	Name       string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %v is open", e.Name)
+}
+
+// Is makes errors.Is(err, ErrOpen) true for every OpenError
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// State is the state of a circuit breaker
+type State string
+
+const (
+	// StateClosed lets every request through
+	StateClosed State = "closed"
+	// StateOpen rejects every request until the open timeout has passed
+	StateOpen State = "open"
+	// StateHalfOpen lets a single trial request through, which closes the breaker if it succeeds
+	StateHalfOpen State = "half-open"
+)
+
+// CircuitBreaker stops requests to a downstream service after consecutive failures, so that callers fail fast instead of waiting for timeouts
+type CircuitBreaker struct {
+	name        string
+	threshold   int
+	openTimeout time.Duration
+
+	mutex    sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+	now      func() time.Time
+}
+
+// New returns a CircuitBreaker which opens after threshold consecutive failures, and stays open for openTimeout
+func New(name string, threshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{name: name, threshold: threshold, openTimeout: openTimeout, state: StateClosed, now: time.Now}
+}
+
+// Name returns the name of the downstream service protected by the breaker
+func (cb *CircuitBreaker) Name() string {
+	return cb.name
+}
+
+// State returns the current state of the breaker
+func (cb *CircuitBreaker) State() State {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if cb.state == StateOpen && !cb.now().Before(cb.openedAt.Add(cb.openTimeout)) {
+		return StateHalfOpen
+	}
+	return cb.state
+}
+
+// Open reports whether the breaker currently rejects requests
+func (cb *CircuitBreaker) Open() bool {
+	return cb.State() == StateOpen
+}
+
+// Allow returns an OpenError if the request must be rejected. Every allowed request must be followed by a call to Record.
+func (cb *CircuitBreaker) Allow() error {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case StateOpen:
+		reopen := cb.openedAt.Add(cb.openTimeout)
+		if cb.now().Before(reopen) {
+			return &OpenError{Name: cb.name, RetryAfter: reopen.Sub(cb.now())}
+		}
+		cb.state = StateHalfOpen
+		cb.trial = true
+		return nil
+	case StateHalfOpen:
+		if cb.trial {
+			return &OpenError{Name: cb.name, RetryAfter: cb.openTimeout}
+		}
+		cb.trial = true
+		return nil
+	}
+	return nil
+}
+
+// Record records the outcome of an allowed request
+func (cb *CircuitBreaker) Record(success bool) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if success {
+		cb.state = StateClosed
+		cb.failures = 0
+		cb.trial = false
+		return
+	}
+
+	cb.failures++
+	if cb.state == StateHalfOpen || cb.failures >= cb.threshold {
+		cb.state = StateOpen
+		cb.openedAt = cb.now()
+		cb.trial = false
+	}
+}
+
+// release gives up an allowed request without recording an outcome
+func (cb *CircuitBreaker) release() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.trial = false
+}
+
+type bypassKey struct{}
+
+// Bypass returns a copy of ctx whose requests are sent without going through the breaker, such as health checks,
+// which would otherwise close the breaker while the requests which matter keep failing
+func Bypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassKey{}).(bool)
+	return bypass
+}
+
+// Transport returns a http.RoundTripper which sends requests through the breaker to next.
+// Connection errors and 5xx responses count as failures. Requests made with a context returned by Bypass are neither rejected nor counted.
+func (cb *CircuitBreaker) Transport(next http.RoundTripper) http.RoundTripper {
+	return &transport{breaker: cb, next: next}
+}
+
+type transport struct {
+	breaker *CircuitBreaker
+	next    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if bypassed(req.Context()) {
+		return t.next.RoundTrip(req)
+	}
+	if err := t.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		if errors.Is(req.Context().Err(), context.Canceled) {
+			// a request cancelled by the caller says nothing about the health of the downstream service
+			t.breaker.release()
+		} else {
+			t.breaker.Record(false)
+		}
+		return nil, err
+	}
+
+	t.breaker.Record(resp.StatusCode < http.StatusInternalServerError)
+	return resp, nil
+}
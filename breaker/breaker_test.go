@@ -0,0 +1,193 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestBreaker(threshold int) (*CircuitBreaker, *testClock) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cb := New("test", threshold, 30*time.Second)
+	cb.now = clock.Now
+	return cb, clock
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	cb, _ := newTestBreaker(3)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, cb.Allow())
+		cb.Record(false)
+	}
+	assert.Equal(t, StateClosed, cb.State())
+
+	require.NoError(t, cb.Allow())
+	cb.Record(false)
+	assert.Equal(t, StateOpen, cb.State())
+	assert.True(t, cb.Open())
+
+	err := cb.Allow()
+	assert.True(t, errors.Is(err, ErrOpen))
+
+	var openErr *OpenError
+	require.True(t, errors.As(err, &openErr))
+	assert.Equal(t, "test", openErr.Name)
+	assert.Equal(t, 30*time.Second, openErr.RetryAfter)
+	assert.EqualError(t, err, "circuit breaker for test is open")
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	cb, _ := newTestBreaker(2)
+
+	require.NoError(t, cb.Allow())
+	cb.Record(false)
+	require.NoError(t, cb.Allow())
+	cb.Record(true)
+	require.NoError(t, cb.Allow())
+	cb.Record(false)
+
+	assert.Equal(t, StateClosed, cb.State())
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	cb, clock := newTestBreaker(1)
+	require.NoError(t, cb.Allow())
+	cb.Record(false)
+
+	clock.now = clock.now.Add(20 * time.Second)
+	var openErr *OpenError
+	require.True(t, errors.As(cb.Allow(), &openErr))
+	assert.Equal(t, 10*time.Second, openErr.RetryAfter)
+
+	clock.now = clock.now.Add(10 * time.Second)
+	assert.Equal(t, StateHalfOpen, cb.State())
+	assert.False(t, cb.Open())
+
+	require.NoError(t, cb.Allow(), "the trial request is let through")
+	assert.True(t, errors.Is(cb.Allow(), ErrOpen), "only one trial request is let through")
+
+	cb.Record(true)
+	assert.Equal(t, StateClosed, cb.State())
+	assert.NoError(t, cb.Allow())
+}
+
+func TestBreakerFailedTrialReopens(t *testing.T) {
+	cb, clock := newTestBreaker(3)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, cb.Allow())
+		cb.Record(false)
+	}
+
+	clock.now = clock.now.Add(30 * time.Second)
+	require.NoError(t, cb.Allow())
+	cb.Record(false)
+
+	assert.Equal(t, StateOpen, cb.State())
+	assert.True(t, errors.Is(cb.Allow(), ErrOpen))
+}
+
+func TestTransport(t *testing.T) {
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	cb, clock := newTestBreaker(2)
+	client := &http.Client{Transport: cb.Transport(http.DefaultTransport)}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	_, err := client.Get(server.URL)
+	assert.True(t, errors.Is(err, ErrOpen))
+
+	status = http.StatusNotFound
+	clock.now = clock.now.Add(30 * time.Second)
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, StateClosed, cb.State(), "4xx responses do not count as failures")
+}
+
+func TestTransportConnectionErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	cb, _ := newTestBreaker(1)
+	client := &http.Client{Transport: cb.Transport(http.DefaultTransport)}
+
+	_, err := client.Get(server.URL)
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrOpen))
+	assert.Equal(t, StateOpen, cb.State())
+}
+
+func TestTransportCancelledRequestIsNotAFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	cb, _ := newTestBreaker(1)
+	client := &http.Client{Transport: cb.Transport(http.DefaultTransport)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	_, err = client.Do(req)
+	require.Error(t, err)
+	assert.Equal(t, StateClosed, cb.State())
+}
+
+func TestTransportBypass(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/__gtg" {
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cb, _ := newTestBreaker(2)
+	client := &http.Client{Transport: cb.Transport(http.DefaultTransport)}
+	healthCheck := func() {
+		req, err := http.NewRequestWithContext(Bypass(context.Background()), http.MethodGet, server.URL+"/__gtg", nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err, "health checks should not be rejected by an open breaker")
+		resp.Body.Close()
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		// a successful health check between failed requests does not reset their count
+		healthCheck()
+	}
+	assert.Equal(t, StateOpen, cb.State())
+	healthCheck()
+	assert.Equal(t, StateOpen, cb.State())
+}
@@ -0,0 +1,167 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// ErrFull occurs when a request is rejected because the bulkhead of the downstream service has no free capacity
+var ErrFull = errors.New("bulkhead is full")
+
+// FullError is returned for requests rejected by a bulkhead, either because its queue was full or because no request finished within the wait timeout
+type FullError struct {
+	Name string
+}
+
+func (e *FullError) Error() string {
+	return fmt.Sprintf("bulkhead for %v is full", e.Name)
+}
+
+// Is makes errors.Is(err, ErrFull) true for every FullError
+func (e *FullError) Is(target error) bool {
+	return target == ErrFull
+}
+
+// Limits is the capacity of a bulkhead
+type Limits struct {
+	// MaxConcurrent is the number of requests in flight at once
+	MaxConcurrent int
+	// MaxQueued is the number of requests waiting for one of the requests in flight to finish
+	MaxQueued int
+	// WaitTimeout is how long a queued request waits before it is rejected
+	WaitTimeout time.Duration
+}
+
+// Bulkhead limits the number of concurrent requests to a downstream service, so that a slow service cannot use up the goroutines and connections needed by the others
+type Bulkhead struct {
+	name   string
+	limits Limits
+	slots  chan struct{}
+
+	queued   int64
+	rejected int64
+}
+
+// New returns a Bulkhead with the given limits
+func New(name string, limits Limits) *Bulkhead {
+	return &Bulkhead{name: name, limits: limits, slots: make(chan struct{}, limits.MaxConcurrent)}
+}
+
+// Name returns the name of the downstream service protected by the bulkhead
+func (b *Bulkhead) Name() string {
+	return b.name
+}
+
+// InFlight returns the number of requests currently holding a slot
+func (b *Bulkhead) InFlight() int {
+	return len(b.slots)
+}
+
+// Queued returns the number of requests currently waiting for a slot
+func (b *Bulkhead) Queued() int {
+	return int(atomic.LoadInt64(&b.queued))
+}
+
+// Rejected returns the number of requests rejected since the bulkhead was created
+func (b *Bulkhead) Rejected() int64 {
+	return atomic.LoadInt64(&b.rejected)
+}
+
+// Acquire waits for a free slot, and returns the function which frees it again. It returns a FullError if the queue is full or the wait times out,
+// and the error of ctx if it is done first.
+func (b *Bulkhead) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case b.slots <- struct{}{}:
+		return b.releaser(), nil
+	default:
+	}
+
+	if atomic.AddInt64(&b.queued, 1) > int64(b.limits.MaxQueued) {
+		atomic.AddInt64(&b.queued, -1)
+		return nil, b.reject()
+	}
+	defer atomic.AddInt64(&b.queued, -1)
+
+	timer := time.NewTimer(b.limits.WaitTimeout)
+	defer timer.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return b.releaser(), nil
+	case <-timer.C:
+		return nil, b.reject()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Bulkhead) reject() error {
+	atomic.AddInt64(&b.rejected, 1)
+	return &FullError{Name: b.name}
+}
+
+func (b *Bulkhead) releaser() func() {
+	var once sync.Once
+	return func() { once.Do(func() { <-b.slots }) }
+}
+
+// RegisterMetrics registers gauges of the requests in flight and queued, and of the requests rejected, named after the bulkhead
+func (b *Bulkhead) RegisterMetrics(registry metrics.Registry) error {
+	gauges := map[string]func() int64{
+		"in-flight": func() int64 { return int64(b.InFlight()) },
+		"queued":    func() int64 { return int64(b.Queued()) },
+		"rejected":  b.Rejected,
+	}
+	for name, value := range gauges {
+		if err := registry.Register(fmt.Sprintf("bulkhead.%v.%v", b.name, name), metrics.NewFunctionalGauge(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transport returns a http.RoundTripper which sends requests through the bulkhead to next.
+// A request holds its slot until the body of its response is closed.
+func (b *Bulkhead) Transport(next http.RoundTripper) http.RoundTripper {
+	return &transport{bulkhead: b, next: next}
+}
+
+type transport struct {
+	bulkhead *Bulkhead
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.bulkhead.Acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody frees the slot of a request once its response has been read
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (rb *releasingBody) Close() error {
+	err := rb.ReadCloser.Close()
+	rb.release()
+	return err
+}
@@ -0,0 +1,127 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkheadRejectsWhenQueueIsFull(t *testing.T) {
+	b := New("test", Limits{MaxConcurrent: 1, MaxQueued: 1, WaitTimeout: time.Minute})
+
+	release, err := b.Acquire(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, b.InFlight())
+
+	queued := make(chan error)
+	go func() {
+		release, err := b.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		queued <- err
+	}()
+	require.Eventually(t, func() bool { return b.Queued() == 1 }, time.Second, time.Millisecond)
+
+	_, err = b.Acquire(context.Background())
+	var fullErr *FullError
+	require.True(t, errors.As(err, &fullErr))
+	assert.Equal(t, "test", fullErr.Name)
+	assert.True(t, errors.Is(err, ErrFull))
+	assert.Equal(t, int64(1), b.Rejected())
+
+	release()
+	release() // releasing twice frees a single slot
+	assert.NoError(t, <-queued)
+	assert.Equal(t, 0, b.InFlight())
+	assert.Equal(t, 0, b.Queued())
+}
+
+func TestBulkheadRejectsAfterWaitTimeout(t *testing.T) {
+	b := New("test", Limits{MaxConcurrent: 1, MaxQueued: 1, WaitTimeout: 10 * time.Millisecond})
+
+	_, err := b.Acquire(context.Background())
+	require.NoError(t, err)
+
+	_, err = b.Acquire(context.Background())
+	assert.True(t, errors.Is(err, ErrFull))
+	assert.Equal(t, int64(1), b.Rejected())
+	assert.Equal(t, 0, b.Queued())
+}
+
+func TestBulkheadStopsWaitingWhenContextIsDone(t *testing.T) {
+	b := New("test", Limits{MaxConcurrent: 1, MaxQueued: 1, WaitTimeout: time.Minute})
+
+	_, err := b.Acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = b.Acquire(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, int64(0), b.Rejected())
+}
+
+func TestTransportHoldsSlotUntilBodyIsClosed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	b := New("test", Limits{MaxConcurrent: 1, MaxQueued: 0, WaitTimeout: time.Minute})
+	client := &http.Client{Transport: b.Transport(http.DefaultTransport)}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 1, b.InFlight())
+
+	_, err = client.Get(server.URL)
+	assert.True(t, errors.Is(err, ErrFull))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "body", string(body))
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, 0, b.InFlight())
+
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestTransportFreesSlotOnError(t *testing.T) {
+	b := New("test", Limits{MaxConcurrent: 1, MaxQueued: 0, WaitTimeout: time.Minute})
+	client := &http.Client{Transport: b.Transport(http.DefaultTransport)}
+
+	_, err := client.Get("http://localhost:1")
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrFull))
+	assert.Equal(t, 0, b.InFlight())
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	b := New("draft-annotations-rw", Limits{MaxConcurrent: 2, MaxQueued: 0, WaitTimeout: time.Minute})
+	registry := metrics.NewRegistry()
+	require.NoError(t, b.RegisterMetrics(registry))
+
+	_, err := b.Acquire(context.Background())
+	require.NoError(t, err)
+
+	inFlight, ok := registry.Get("bulkhead.draft-annotations-rw.in-flight").(metrics.Gauge)
+	require.True(t, ok)
+	assert.Equal(t, int64(1), inFlight.Value())
+
+	var names []string
+	registry.Each(func(name string, _ interface{}) {
+		names = append(names, name)
+	})
+	assert.ElementsMatch(t, []string{"bulkhead.draft-annotations-rw.in-flight", "bulkhead.draft-annotations-rw.queued", "bulkhead.draft-annotations-rw.rejected"}, names)
+}
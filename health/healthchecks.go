@@ -1,6 +1,7 @@
 package health
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -13,17 +14,24 @@ type ExternalService interface {
 	GTG() error
 }
 
+// CircuitBreaker reports whether requests to a downstream service are currently rejected
+type CircuitBreaker interface {
+	Name() string
+	Open() bool
+}
+
 // HealthService runs application health checks, and provides the /__health http endpoint
 type HealthService struct {
 	fthealth.HealthCheck
 	publisher ExternalService
 	writer    ExternalService
 	draftsRW  ExternalService
+	breakers  []CircuitBreaker
 }
 
 // NewHealthService returns a new HealthService
-func NewHealthService(appSystemCode string, appName string, appDescription string, publisher ExternalService, writer ExternalService, draftsRW ExternalService) *HealthService {
-	service := &HealthService{publisher: publisher, writer: writer, draftsRW: draftsRW}
+func NewHealthService(appSystemCode string, appName string, appDescription string, publisher ExternalService, writer ExternalService, draftsRW ExternalService, breakers ...CircuitBreaker) *HealthService {
+	service := &HealthService{publisher: publisher, writer: writer, draftsRW: draftsRW, breakers: breakers}
 	service.SystemCode = appSystemCode
 	service.Name = appName
 	service.Description = appDescription
@@ -32,6 +40,9 @@ func NewHealthService(appSystemCode string, appName string, appDescription strin
 		service.publishCheck(),
 		service.draftsCheck(),
 	}
+	for _, cb := range breakers {
+		service.Checks = append(service.Checks, service.circuitBreakerCheck(cb))
+	}
 	return service
 }
 
@@ -97,6 +108,24 @@ func (service *HealthService) draftsHealthChecker() (string, error) {
 	return "PAC drafts annotations reader writer is healthy", nil
 }
 
+func (service *HealthService) circuitBreakerCheck(cb CircuitBreaker) fthealth.Check {
+	return fthealth.Check{
+		ID:               fmt.Sprintf("check-%v-circuit-breaker", cb.Name()),
+		BusinessImpact:   "Annotations cannot be published to UPP",
+		Name:             fmt.Sprintf("Check the circuit breaker for %v", cb.Name()),
+		PanicGuide:       "https://dewey.ft.com/annotations-publisher.html",
+		Severity:         1,
+		TechnicalSummary: fmt.Sprintf("The circuit breaker for %v is open after repeated failures, so requests to it are rejected until it recovers", cb.Name()),
+		Checker: func() (string, error) {
+			if cb.Open() {
+				msg := fmt.Sprintf("Circuit breaker for %v is open", cb.Name())
+				return msg, errors.New(msg)
+			}
+			return fmt.Sprintf("Circuit breaker for %v is closed", cb.Name()), nil
+		},
+	}
+}
+
 func (service *HealthService) GTG() gtg.Status {
 
 	writerCheck := func() gtg.Status {
@@ -108,6 +137,18 @@ func (service *HealthService) GTG() gtg.Status {
 		return gtg.Status{GoodToGo: true, Message: "OK"}
 	}
 
-	// switch to 'gtg.FailFastParallelCheck' if there are multiple checkers in the future.
-	return gtg.FailFastSequentialChecker([]gtg.StatusChecker{writerCheck})()
+	checks := []gtg.StatusChecker{writerCheck}
+	for _, cb := range service.breakers {
+		check := service.circuitBreakerCheck(cb)
+		checks = append(checks, func() gtg.Status {
+			msg, err := check.Checker()
+			if err != nil {
+				return gtg.Status{GoodToGo: false, Message: msg}
+			}
+			return gtg.Status{GoodToGo: true, Message: "OK"}
+		})
+	}
+
+	// circuit breaker checks do not make requests, switch to 'gtg.FailFastParallelCheck' if there are multiple checkers making requests in the future.
+	return gtg.FailFastSequentialChecker(checks)()
 }
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPublishedAnnotationsWriterCheck(t *testing.T) {
@@ -141,3 +142,43 @@ func (m *mockGtg) GTG() error {
 func (m *mockGtg) Endpoint() string {
 	return m.endpoint
 }
+
+func TestCircuitBreakerCheck(t *testing.T) {
+	breaker := &mockBreaker{name: "upp-publish"}
+	health := NewHealthService("appSystemCode", "appName", "appDescription", &mockGtg{}, &mockGtg{}, &mockGtg{}, breaker)
+	require.Len(t, health.Checks, 4)
+
+	check := health.Checks[3]
+	assert.Equal(t, "check-upp-publish-circuit-breaker", check.ID)
+	assert.Equal(t, "Check the circuit breaker for upp-publish", check.Name)
+
+	msg, err := check.Checker()
+	assert.Equal(t, "Circuit breaker for upp-publish is closed", msg)
+	assert.NoError(t, err)
+
+	breaker.open = true
+	msg, err = check.Checker()
+	assert.Equal(t, "Circuit breaker for upp-publish is open", msg)
+	assert.EqualError(t, err, "Circuit breaker for upp-publish is open")
+}
+
+func TestGTGFailsWhenCircuitBreakerIsOpen(t *testing.T) {
+	health := NewHealthService("appSystemCode", "appName", "appDescription", &mockGtg{}, &mockGtg{}, &mockGtg{}, &mockBreaker{name: "draft-annotations-rw"}, &mockBreaker{name: "upp-publish", open: true})
+
+	gtg := health.GTG()
+	assert.False(t, gtg.GoodToGo)
+	assert.Equal(t, "Circuit breaker for upp-publish is open", gtg.Message)
+}
+
+type mockBreaker struct {
+	name string
+	open bool
+}
+
+func (m *mockBreaker) Name() string {
+	return m.name
+}
+
+func (m *mockBreaker) Open() bool {
+	return m.open
+}
@@ -0,0 +1,100 @@
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInProgress occurs when a request with the same key is still being handled
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrMismatch occurs when a key is reused for a request which differs from the first request with that key
+	ErrMismatch = errors.New("the idempotency key has already been used for a different request")
+)
+
+// Response is the stored response to the first request with a key, which is replayed to the repeated requests
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type entry struct {
+	key         string
+	fingerprint string
+	// response is nil while the first request is in progress
+	response *Response
+	expires  time.Time
+}
+
+// Cache keeps the responses to requests by their idempotency key for a fixed window after the first request
+type Cache struct {
+	window time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*entry
+	// order holds the entries by expiry, which is the order they were begun in since the window is fixed
+	order []*entry
+	now   func() time.Time
+}
+
+// NewCache returns a Cache which keeps responses for the given window
+func NewCache(window time.Duration) *Cache {
+	return &Cache{window: window, entries: make(map[string]*entry), now: time.Now}
+}
+
+// Begin reserves the key for a request identified by fingerprint. It returns the stored response if a request with the same key and fingerprint has completed,
+// or nil if the caller should handle the request and then call Complete or Abandon.
+// It returns ErrMismatch if the key was used with a different fingerprint, and ErrInProgress if the first request with the key has not completed.
+func (c *Cache) Begin(key string, fingerprint string) (*Response, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.expire()
+	if e, ok := c.entries[key]; ok {
+		if e.fingerprint != fingerprint {
+			return nil, ErrMismatch
+		}
+		if e.response == nil {
+			return nil, ErrInProgress
+		}
+		return e.response, nil
+	}
+
+	e := &entry{key: key, fingerprint: fingerprint, expires: c.now().Add(c.window)}
+	c.entries[key] = e
+	c.order = append(c.order, e)
+	return nil, nil
+}
+
+// Complete stores the response to the request which reserved the key, to be replayed until the window expires
+func (c *Cache) Complete(key string, resp Response) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.response = &resp
+	}
+}
+
+// Abandon releases the key without storing a response, so a repeated request is handled again
+func (c *Cache) Abandon(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, key)
+}
+
+func (c *Cache) expire() {
+	now := c.now()
+	for len(c.order) > 0 && !now.Before(c.order[0].expires) {
+		e := c.order[0]
+		// the key may have been abandoned and reserved again since
+		if c.entries[e.key] == e {
+			delete(c.entries, e.key)
+		}
+		c.order = c.order[1:]
+	}
+}
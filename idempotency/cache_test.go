@@ -0,0 +1,56 @@
+package idempotency
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	c := NewCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	stored, err := c.Begin("key", "fingerprint")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	_, err = c.Begin("key", "fingerprint")
+	assert.Equal(t, ErrInProgress, err)
+	_, err = c.Begin("key", "other-fingerprint")
+	assert.Equal(t, ErrMismatch, err)
+
+	resp := Response{Status: http.StatusAccepted, Header: http.Header{"Document-Hash": []string{"hash"}}, Body: []byte(`{"message":"Publish accepted"}`)}
+	c.Complete("key", resp)
+
+	stored, err = c.Begin("key", "fingerprint")
+	require.NoError(t, err)
+	assert.Equal(t, &resp, stored)
+
+	now = now.Add(time.Minute)
+	stored, err = c.Begin("key", "other-fingerprint")
+	require.NoError(t, err)
+	assert.Nil(t, stored, "the key should have expired")
+}
+
+func TestCacheAbandon(t *testing.T) {
+	now := time.Now()
+	c := NewCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	_, err := c.Begin("key", "fingerprint")
+	require.NoError(t, err)
+	c.Abandon("key")
+
+	now = now.Add(30 * time.Second)
+	stored, err := c.Begin("key", "fingerprint")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	now = now.Add(45 * time.Second)
+	_, err = c.Begin("key", "fingerprint")
+	assert.Equal(t, ErrInProgress, err, "expiring the abandoned entry should not expire the key reserved again")
+}
@@ -0,0 +1,242 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/outbound"
+	"github.com/Financial-Times/annotations-publisher/tracing"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/pborman/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Financial-Times/annotations-publisher/jobs")
+
+// ErrQueueFull occurs when a job is submitted while every slot in the queue is taken
+var ErrQueueFull = errors.New("publish job queue is full")
+
+// State is the lifecycle state of a publish job
+type State string
+
+const (
+	StateQueued     State = "queued"
+	StateInProgress State = "in-progress"
+	StateSucceeded  State = "succeeded"
+	StateFailed     State = "failed"
+)
+
+// Job is a publish which runs in the background
+type Job struct {
+	ID            string    `json:"id"`
+	UUID          string    `json:"uuid"`
+	TransactionID string    `json:"transactionId"`
+	Editor        string    `json:"editor,omitempty"`
+	FromStore     bool      `json:"fromStore"`
+	Merge         bool      `json:"merge,omitempty"`
+	State         State     `json:"state"`
+	Error         string    `json:"error,omitempty"`
+	Rollback      string    `json:"rollback,omitempty"`
+	DocumentHash  string    `json:"documentHash,omitempty"`
+	Created       time.Time `json:"created"`
+	Updated       time.Time `json:"updated"`
+
+	hash string
+	body annotations.AnnotationsBody
+	// headers are sent on to downstream services, as they are for the request which submitted the job
+	headers http.Header
+	// spanContext is the span of the request which submitted the job, which is the parent of the span of the job
+	spanContext trace.SpanContext
+}
+
+func (j *Job) finished() bool {
+	return j.State == StateSucceeded || j.State == StateFailed
+}
+
+// Queue runs publish jobs with a fixed number of workers, and keeps finished jobs for the configured retention period
+type Queue struct {
+	publisher annotations.Publisher
+	timeout   time.Duration
+	retention time.Duration
+	log       *logger.UPPLogger
+
+	mutex   sync.RWMutex
+	jobs    map[string]*Job
+	pending chan *Job
+	// queuedFromStore holds the publish from store of every piece of content which has one waiting to be run
+	queuedFromStore map[string]*Job
+	wg              sync.WaitGroup
+	now             func() time.Time
+}
+
+// NewQueue returns a Queue which accepts at most size pending jobs. Call Start to begin processing them.
+func NewQueue(publisher annotations.Publisher, size int, timeout time.Duration, retention time.Duration, log *logger.UPPLogger) *Queue {
+	return &Queue{
+		publisher:       publisher,
+		timeout:         timeout,
+		retention:       retention,
+		log:             log,
+		jobs:            make(map[string]*Job),
+		pending:         make(chan *Job, size),
+		queuedFromStore: make(map[string]*Job),
+		now:             time.Now,
+	}
+}
+
+// Start runs the given number of workers until Stop is called
+func (q *Queue) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for job := range q.pending {
+				q.run(job)
+			}
+		}()
+	}
+}
+
+// Stop stops accepting jobs and waits for the pending ones to finish
+func (q *Queue) Stop() {
+	close(q.pending)
+	q.wg.Wait()
+}
+
+// SubmitFromStore queues a publish from store for the given content. If one is already waiting to be run, it is returned instead,
+// since it will publish the draft annotations as they are when it runs.
+// The job runs with the transaction ID, editor and outbound headers of ctx, but not its deadline.
+func (q *Queue) SubmitFromStore(ctx context.Context, contentUUID string) (Job, error) {
+	return q.submit(ctx, &Job{UUID: contentUUID, FromStore: true})
+}
+
+// SubmitSaveAndPublish queues a save and publish of the given annotations
+func (q *Queue) SubmitSaveAndPublish(ctx context.Context, contentUUID string, hash string, body annotations.AnnotationsBody) (Job, error) {
+	return q.submit(ctx, &Job{UUID: contentUUID, hash: hash, body: body})
+}
+
+// SubmitMergeAndPublish queues a save and publish of the given annotations, which are merged with concurrent changes to the draft annotations
+func (q *Queue) SubmitMergeAndPublish(ctx context.Context, contentUUID string, hash string, body annotations.AnnotationsBody) (Job, error) {
+	return q.submit(ctx, &Job{UUID: contentUUID, Merge: true, hash: hash, body: body})
+}
+
+// Get returns a copy of the job with the given id
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func (q *Queue) submit(ctx context.Context, job *Job) (Job, error) {
+	job.TransactionID, _ = tid.GetTransactionIDFromContext(ctx)
+	job.Editor = annotations.EditorFromContext(ctx)
+	job.headers = outbound.Headers(ctx)
+	job.spanContext = trace.SpanContextFromContext(ctx)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.purge()
+
+	if queued, ok := q.queuedFromStore[job.UUID]; ok && job.FromStore {
+		q.log.WithFields(map[string]interface{}{"transaction_id": job.TransactionID, "uuid": job.UUID, "job_id": queued.ID}).Info("publish from store is already queued")
+		return *queued, nil
+	}
+
+	now := q.now()
+	job.ID = uuid.New()
+	job.State = StateQueued
+	job.Created = now
+	job.Updated = now
+
+	select {
+	case q.pending <- job:
+	default:
+		return Job{}, ErrQueueFull
+	}
+
+	q.jobs[job.ID] = job
+	if job.FromStore {
+		q.queuedFromStore[job.UUID] = job
+	}
+	return *job, nil
+}
+
+// purge removes the finished jobs which are older than the retention period. The caller must hold the write lock.
+func (q *Queue) purge() {
+	expiry := q.now().Add(-q.retention)
+	for id, job := range q.jobs {
+		if job.finished() && job.Updated.Before(expiry) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+func (q *Queue) run(job *Job) {
+	mlog := q.log.WithFields(map[string]interface{}{"transaction_id": job.TransactionID, "uuid": job.UUID, "job_id": job.ID})
+	q.update(job, func(j *Job) {
+		j.State = StateInProgress
+		if q.queuedFromStore[j.UUID] == j {
+			delete(q.queuedFromStore, j.UUID)
+		}
+	})
+
+	ctx := annotations.WithEditor(tid.TransactionAwareContext(context.Background(), job.TransactionID), job.Editor)
+	ctx = outbound.WithHeaders(ctx, job.headers)
+	// the span of the submitting request has usually ended by now, but the job is still part of its trace
+	ctx, span := tracing.Start(trace.ContextWithSpanContext(ctx, job.spanContext), tracer, "PublishJob", job.UUID, trace.WithSpanKind(trace.SpanKindConsumer))
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	var hash string
+	var err error
+	if job.FromStore {
+		hash, err = q.publisher.PublishFromStore(ctx, job.UUID)
+	} else if job.Merge {
+		hash, err = q.publisher.MergeAndPublish(ctx, job.UUID, job.hash, job.body)
+	} else {
+		hash, err = q.publisher.SaveAndPublish(ctx, job.UUID, job.hash, job.body)
+	}
+
+	tracing.End(span, err)
+
+	if err != nil {
+		mlog.WithError(err).Error("publish job failed")
+
+		var rollbackErr *annotations.RollbackError
+		errors.As(err, &rollbackErr)
+		q.update(job, func(j *Job) {
+			j.State = StateFailed
+			j.Error = err.Error()
+			if rollbackErr != nil {
+				j.Rollback = string(rollbackErr.Outcome)
+			}
+		})
+		return
+	}
+
+	mlog.Info("publish job succeeded")
+	q.update(job, func(j *Job) {
+		j.State = StateSucceeded
+		j.DocumentHash = hash
+	})
+}
+
+// update applies the change to the job while holding the write lock, so readers never see a partially updated job
+func (q *Queue) update(job *Job, change func(j *Job)) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	change(job)
+	job.Updated = q.now()
+}
@@ -0,0 +1,225 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testAnnotations = annotations.AnnotationsBody{Annotations: []annotations.Annotation{
+	{
+		Predicate: "foo",
+		ConceptID: "bar",
+	},
+}}
+
+func TestQueueRunsSaveAndPublishJob(t *testing.T) {
+	pub := &mockPublisher{}
+	pub.On("SaveAndPublish", mock.MatchedBy(hasTransactionID("tid_test")), "a-valid-uuid", "hash", testAnnotations).Return("new-hash", nil)
+
+	queue := NewQueue(pub, 10, time.Second, time.Hour, logger.NewUPPLogger("test", "DEBUG"))
+	queue.Start(1)
+
+	job, err := queue.SubmitSaveAndPublish(testContext("tid_test", ""), "a-valid-uuid", "hash", testAnnotations)
+	require.NoError(t, err)
+	assert.Equal(t, StateQueued, job.State)
+	assert.NotEmpty(t, job.ID)
+
+	queue.Stop()
+
+	actual, ok := queue.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, StateSucceeded, actual.State)
+	assert.Equal(t, "new-hash", actual.DocumentHash)
+	assert.Empty(t, actual.Error)
+
+	pub.AssertExpectations(t)
+}
+
+func TestQueueRunsJobAsEditor(t *testing.T) {
+	pub := &mockPublisher{}
+	pub.On("PublishFromStore", mock.MatchedBy(func(ctx context.Context) bool {
+		return annotations.EditorFromContext(ctx) == "an-editor"
+	}), "a-valid-uuid").Return("new-hash", nil)
+
+	queue := NewQueue(pub, 10, time.Second, time.Hour, logger.NewUPPLogger("test", "DEBUG"))
+	queue.Start(1)
+
+	job, err := queue.SubmitFromStore(testContext("tid_test", "an-editor"), "a-valid-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, "an-editor", job.Editor)
+
+	queue.Stop()
+	pub.AssertExpectations(t)
+}
+
+func TestQueueRunsFailingFromStoreJob(t *testing.T) {
+	pub := &mockPublisher{}
+	pub.On("PublishFromStore", mock.Anything, "a-valid-uuid").Return("", errors.New("eek"))
+
+	queue := NewQueue(pub, 10, time.Second, time.Hour, logger.NewUPPLogger("test", "DEBUG"))
+	queue.Start(1)
+
+	job, err := queue.SubmitFromStore(testContext("tid_test", ""), "a-valid-uuid")
+	require.NoError(t, err)
+
+	queue.Stop()
+
+	actual, ok := queue.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, StateFailed, actual.State)
+	assert.Equal(t, "eek", actual.Error)
+	assert.True(t, actual.FromStore)
+
+	pub.AssertExpectations(t)
+}
+
+func TestQueueCollapsesQueuedFromStoreJobs(t *testing.T) {
+	pub := &mockPublisher{}
+	pub.On("PublishFromStore", mock.Anything, "a-valid-uuid").Return("new-hash", nil).Once()
+	pub.On("PublishFromStore", mock.Anything, "another-valid-uuid").Return("new-hash", nil).Once()
+
+	queue := NewQueue(pub, 10, time.Second, time.Hour, logger.NewUPPLogger("test", "DEBUG"))
+
+	first, err := queue.SubmitFromStore(testContext("tid_first", ""), "a-valid-uuid")
+	require.NoError(t, err)
+	repeat, err := queue.SubmitFromStore(testContext("tid_repeat", ""), "a-valid-uuid")
+	require.NoError(t, err)
+	other, err := queue.SubmitFromStore(testContext("tid_other", ""), "another-valid-uuid")
+	require.NoError(t, err)
+
+	assert.Equal(t, first.ID, repeat.ID)
+	assert.NotEqual(t, first.ID, other.ID)
+
+	queue.Start(1)
+	queue.Stop()
+
+	actual, ok := queue.Get(first.ID)
+	require.True(t, ok)
+	assert.Equal(t, StateSucceeded, actual.State)
+
+	pub.AssertExpectations(t)
+}
+
+func TestQueueFull(t *testing.T) {
+	pub := &mockPublisher{}
+	queue := NewQueue(pub, 1, time.Second, time.Hour, logger.NewUPPLogger("test", "DEBUG"))
+
+	_, err := queue.SubmitFromStore(testContext("tid_test", ""), "a-valid-uuid")
+	require.NoError(t, err)
+
+	_, err = queue.SubmitFromStore(testContext("tid_test", ""), "another-valid-uuid")
+	assert.Equal(t, ErrQueueFull, err)
+}
+
+func TestQueuePurgesExpiredJobs(t *testing.T) {
+	pub := &mockPublisher{}
+	pub.On("PublishFromStore", mock.Anything, "a-valid-uuid").Return("new-hash", nil)
+
+	queue := NewQueue(pub, 10, time.Second, time.Minute, logger.NewUPPLogger("test", "DEBUG"))
+	now := time.Now()
+	queue.now = func() time.Time { return now }
+	queue.Start(1)
+
+	job, err := queue.SubmitFromStore(testContext("tid_test", ""), "a-valid-uuid")
+	require.NoError(t, err)
+
+	// wait for the job to finish
+	require.Eventually(t, func() bool {
+		actual, _ := queue.Get(job.ID)
+		return actual.State == StateSucceeded
+	}, time.Second, 10*time.Millisecond)
+
+	queue.mutex.Lock()
+	now = now.Add(2 * time.Minute)
+	queue.mutex.Unlock()
+
+	queued, err := queue.SubmitFromStore(testContext("tid_test", ""), "a-valid-uuid")
+	require.NoError(t, err)
+
+	queue.Stop()
+
+	_, ok := queue.Get(job.ID)
+	assert.False(t, ok)
+	_, ok = queue.Get(queued.ID)
+	assert.True(t, ok)
+}
+
+func TestGetUnknownJob(t *testing.T) {
+	queue := NewQueue(&mockPublisher{}, 1, time.Second, time.Hour, logger.NewUPPLogger("test", "DEBUG"))
+
+	_, ok := queue.Get("unknown")
+	assert.False(t, ok)
+}
+
+func testContext(txid string, editor string) context.Context {
+	return annotations.WithEditor(tid.TransactionAwareContext(context.Background(), txid), editor)
+}
+
+func hasTransactionID(expected string) func(ctx context.Context) bool {
+	return func(ctx context.Context) bool {
+		txid, err := tid.GetTransactionIDFromContext(ctx)
+		return err == nil && txid == expected
+	}
+}
+
+type mockPublisher struct {
+	mock.Mock
+}
+
+func (m *mockPublisher) GTG() error {
+	return nil
+}
+
+func (m *mockPublisher) Endpoint() string {
+	return ""
+}
+
+func (m *mockPublisher) Publish(ctx context.Context, uuid string, body map[string]interface{}) error {
+	args := m.Called(ctx, uuid, body)
+	return args.Error(0)
+}
+
+func (m *mockPublisher) PublishFromStore(ctx context.Context, uuid string) (string, error) {
+	args := m.Called(ctx, uuid)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockPublisher) SaveAndPublish(ctx context.Context, uuid string, hash string, body annotations.AnnotationsBody) (string, error) {
+	args := m.Called(ctx, uuid, hash, body)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockPublisher) MergeAndPublish(ctx context.Context, uuid string, hash string, body annotations.AnnotationsBody) (string, error) {
+	args := m.Called(ctx, uuid, hash, body)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockPublisher) UnpublishedChanges(ctx context.Context, uuid string) (annotations.AnnotationsDiff, string, error) {
+	args := m.Called(ctx, uuid)
+	return args.Get(0).(annotations.AnnotationsDiff), args.String(1), args.Error(2)
+}
+
+func (m *mockPublisher) Republish(ctx context.Context, uuid string) (string, error) {
+	args := m.Called(ctx, uuid)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockPublisher) Unpublish(ctx context.Context, uuid string) error {
+	args := m.Called(ctx, uuid)
+	return args.Error(0)
+}
+
+func (m *mockPublisher) PreviewPublish(ctx context.Context, uuid string, hash string, body *annotations.AnnotationsBody, merge bool) (map[string]interface{}, error) {
+	args := m.Called(ctx, uuid, hash, body, merge)
+	payload, _ := args.Get(0).(map[string]interface{})
+	return payload, args.Error(1)
+}
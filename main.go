@@ -1,13 +1,28 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/audit"
+	"github.com/Financial-Times/annotations-publisher/auth"
+	"github.com/Financial-Times/annotations-publisher/backfill"
+	"github.com/Financial-Times/annotations-publisher/breaker"
+	"github.com/Financial-Times/annotations-publisher/bulkhead"
 	"github.com/Financial-Times/annotations-publisher/health"
+	"github.com/Financial-Times/annotations-publisher/idempotency"
+	"github.com/Financial-Times/annotations-publisher/jobs"
+	"github.com/Financial-Times/annotations-publisher/outbound"
+	"github.com/Financial-Times/annotations-publisher/ratelimit"
 	"github.com/Financial-Times/annotations-publisher/resources"
+	"github.com/Financial-Times/annotations-publisher/retryqueue"
+	"github.com/Financial-Times/annotations-publisher/telemetry"
+	"github.com/Financial-Times/annotations-publisher/tracing"
 	"github.com/Financial-Times/api-endpoint"
 	"github.com/Financial-Times/go-ft-http/fthttp"
 	"github.com/Financial-Times/go-logger/v2"
@@ -97,39 +112,626 @@ func main() {
 		EnvVar: "HTTP_CLIENT_TIMEOUT",
 	})
 
+	batchConcurrency := app.Int(cli.IntOpt{
+		Name:   "batch-publish-concurrency",
+		Value:  8,
+		Desc:   "Maximum number of publishes run in parallel for a single batch publish request",
+		EnvVar: "BATCH_PUBLISH_CONCURRENCY",
+	})
+
+	batchMaxItems := app.Int(cli.IntOpt{
+		Name:   "batch-publish-max-items",
+		Value:  500,
+		Desc:   "Maximum number of items accepted in a single batch publish request",
+		EnvVar: "BATCH_PUBLISH_MAX_ITEMS",
+	})
+
+	publishJobWorkers := app.Int(cli.IntOpt{
+		Name:   "publish-job-workers",
+		Value:  4,
+		Desc:   "Number of workers running asynchronous publish jobs",
+		EnvVar: "PUBLISH_JOB_WORKERS",
+	})
+
+	publishJobQueueSize := app.Int(cli.IntOpt{
+		Name:   "publish-job-queue-size",
+		Value:  1000,
+		Desc:   "Maximum number of asynchronous publish jobs waiting to be run",
+		EnvVar: "PUBLISH_JOB_QUEUE_SIZE",
+	})
+
+	publishJobRetention := app.String(cli.StringOpt{
+		Name:   "publish-job-retention",
+		Value:  "1h",
+		Desc:   "How long the status of a finished asynchronous publish job is kept",
+		EnvVar: "PUBLISH_JOB_RETENTION",
+	})
+
+	publishRetryDir := app.String(cli.StringOpt{
+		Name:   "publish-retry-dir",
+		Value:  "",
+		Desc:   "Directory where failed UPP publishes are kept until they are retried. Failed publishes are not retried if empty",
+		EnvVar: "PUBLISH_RETRY_DIR",
+	})
+
+	publishRetryInterval := app.String(cli.StringOpt{
+		Name:   "publish-retry-interval",
+		Value:  "10s",
+		Desc:   "How often the publish retry queue is checked for publishes due to be retried",
+		EnvVar: "PUBLISH_RETRY_INTERVAL",
+	})
+
+	publishRetryInitialBackoff := app.String(cli.StringOpt{
+		Name:   "publish-retry-initial-backoff",
+		Value:  "30s",
+		Desc:   "Delay before a failed UPP publish is retried for the first time",
+		EnvVar: "PUBLISH_RETRY_INITIAL_BACKOFF",
+	})
+
+	publishRetryMaxBackoff := app.String(cli.StringOpt{
+		Name:   "publish-retry-max-backoff",
+		Value:  "1h",
+		Desc:   "Maximum delay between retries of a failed UPP publish",
+		EnvVar: "PUBLISH_RETRY_MAX_BACKOFF",
+	})
+
+	publishRetryMaxAttempts := app.Int(cli.IntOpt{
+		Name:   "publish-retry-max-attempts",
+		Value:  10,
+		Desc:   "Number of automatic retries of a failed UPP publish before it is left for manual action. Zero retries forever",
+		EnvVar: "PUBLISH_RETRY_MAX_ATTEMPTS",
+	})
+
+	transactionalPublish := app.Bool(cli.BoolOpt{
+		Name:   "transactional-publish",
+		Value:  false,
+		Desc:   "Restore the previously published annotations if the publish to UPP fails",
+		EnvVar: "TRANSACTIONAL_PUBLISH",
+	})
+
+	rwRetryMaxAttempts := app.Int(cli.IntOpt{
+		Name:   "rw-retry-max-attempts",
+		Value:  3,
+		Desc:   "Maximum number of attempts of a read or write to the draft and published annotations r/w services",
+		EnvVar: "RW_RETRY_MAX_ATTEMPTS",
+	})
+
+	rwRetryInitialBackoff := app.String(cli.StringOpt{
+		Name:   "rw-retry-initial-backoff",
+		Value:  "100ms",
+		Desc:   "Delay before the first retry of a failed read or write to the annotations r/w services",
+		EnvVar: "RW_RETRY_INITIAL_BACKOFF",
+	})
+
+	rwRetryMaxBackoff := app.String(cli.StringOpt{
+		Name:   "rw-retry-max-backoff",
+		Value:  "1s",
+		Desc:   "Maximum delay between retries of a failed read or write to the annotations r/w services",
+		EnvVar: "RW_RETRY_MAX_BACKOFF",
+	})
+
+	rwRetryJitter := app.Float64(cli.Float64Opt{
+		Name:   "rw-retry-jitter",
+		Value:  0.2,
+		Desc:   "Fraction of the retry backoff which is randomly added or removed, between 0 and 1",
+		EnvVar: "RW_RETRY_JITTER",
+	})
+
+	rwRetryStatusCodes := app.Ints(cli.IntsOpt{
+		Name:   "rw-retry-status-codes",
+		Value:  []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		Desc:   "Responses of the annotations r/w services which are retried. Conflicts are never retried",
+		EnvVar: "RW_RETRY_STATUS_CODES",
+	})
+
+	circuitBreakerThreshold := app.Int(cli.IntOpt{
+		Name:   "circuit-breaker-failure-threshold",
+		Value:  5,
+		Desc:   "Number of consecutive failed requests to a downstream service which opens its circuit breaker. 0 disables the circuit breakers",
+		EnvVar: "CIRCUIT_BREAKER_FAILURE_THRESHOLD",
+	})
+
+	circuitBreakerOpenTimeout := app.String(cli.StringOpt{
+		Name:   "circuit-breaker-open-timeout",
+		Value:  "30s",
+		Desc:   "Time an open circuit breaker rejects requests for, before it lets a trial request through to the downstream service",
+		EnvVar: "CIRCUIT_BREAKER_OPEN_TIMEOUT",
+	})
+
+	bulkheadMaxConcurrent := app.Int(cli.IntOpt{
+		Name:   "bulkhead-max-concurrent",
+		Value:  32,
+		Desc:   "Maximum number of requests in flight to each downstream service. 0 disables the bulkheads",
+		EnvVar: "BULKHEAD_MAX_CONCURRENT",
+	})
+
+	bulkheadMaxQueued := app.Int(cli.IntOpt{
+		Name:   "bulkhead-max-queued",
+		Value:  64,
+		Desc:   "Maximum number of requests to each downstream service waiting for a request in flight to finish",
+		EnvVar: "BULKHEAD_MAX_QUEUED",
+	})
+
+	bulkheadWaitTimeout := app.String(cli.StringOpt{
+		Name:   "bulkhead-wait-timeout",
+		Value:  "1s",
+		Desc:   "How long a request to a downstream service waits for a request in flight to finish before it is rejected",
+		EnvVar: "BULKHEAD_WAIT_TIMEOUT",
+	})
+
+	mergeBaseVersions := app.Int(cli.IntOpt{
+		Name:   "merge-base-versions",
+		Value:  10000,
+		Desc:   "Number of recently read or written draft annotations versions kept in memory as the base of publishes with merge=true. 0 disables merging",
+		EnvVar: "MERGE_BASE_VERSIONS",
+	})
+
+	publishHistoryStore := app.String(cli.StringOpt{
+		Name:   "publish-history-store",
+		Value:  "memory",
+		Desc:   "Where the history of publish attempts is kept: memory, file or none",
+		EnvVar: "PUBLISH_HISTORY_STORE",
+	})
+
+	publishHistoryDir := app.String(cli.StringOpt{
+		Name:   "publish-history-dir",
+		Value:  "./publish-history",
+		Desc:   "Directory where the history of publish attempts is kept when the publish history store is file",
+		EnvVar: "PUBLISH_HISTORY_DIR",
+	})
+
+	publishHistoryMaxRecords := app.Int(cli.IntOpt{
+		Name:   "publish-history-max-records",
+		Value:  100,
+		Desc:   "Number of the most recent publish attempts kept for every piece of content when the publish history store is memory",
+		EnvVar: "PUBLISH_HISTORY_MAX_RECORDS",
+	})
+
+	publishHistoryMaxContent := app.Int(cli.IntOpt{
+		Name:   "publish-history-max-content",
+		Value:  10000,
+		Desc:   "Number of the most recently published pieces of content whose publish history is kept when the publish history store is memory. 0 keeps the history of every piece of content",
+		EnvVar: "PUBLISH_HISTORY_MAX_CONTENT",
+	})
+
+	validateAnnotations := app.Bool(cli.BoolOpt{
+		Name:   "validate-annotations",
+		Value:  false,
+		Desc:   "Reject annotations with unknown predicates or concept types, malformed concept IDs or duplicates before they are saved or published",
+		EnvVar: "VALIDATE_ANNOTATIONS",
+	})
+
+	annotationPredicates := app.Strings(cli.StringsOpt{
+		Name:   "annotation-predicates",
+		Value:  annotations.DefaultPredicates,
+		Desc:   "Predicates allowed in published annotations. Any predicate is allowed if empty",
+		EnvVar: "ANNOTATION_PREDICATES",
+	})
+
+	conceptTypes := app.Strings(cli.StringsOpt{
+		Name:   "concept-types",
+		Value:  annotations.DefaultConceptTypes,
+		Desc:   "Concept types allowed in published annotations. Any type is allowed if empty",
+		EnvVar: "CONCEPT_TYPES",
+	})
+
+	annotationRules := app.String(cli.StringOpt{
+		Name:   "annotation-rules",
+		Value:  "./annotation-rules.yml",
+		Desc:   "Location of the YAML file declaring which concept types every predicate can be used with, and how many times. No rules are enforced if empty",
+		EnvVar: "ANNOTATION_RULES",
+	})
+
+	idempotencyWindow := app.String(cli.StringOpt{
+		Name:   "idempotency-window",
+		Value:  "1h",
+		Desc:   "How long the response to a publish with an Idempotency-Key header is replayed to repeats of it. 0 disables idempotency keys",
+		EnvVar: "IDEMPOTENCY_WINDOW",
+	})
+
+	rateLimitGlobalRate := app.Float64(cli.Float64Opt{
+		Name:   "rate-limit-global-rate",
+		Value:  50,
+		Desc:   "Maximum number of publish requests accepted every second from all clients. 0 disables the limit",
+		EnvVar: "RATE_LIMIT_GLOBAL_RATE",
+	})
+
+	rateLimitGlobalBurst := app.Int(cli.IntOpt{
+		Name:   "rate-limit-global-burst",
+		Value:  100,
+		Desc:   "Maximum number of publish requests accepted at once from all clients",
+		EnvVar: "RATE_LIMIT_GLOBAL_BURST",
+	})
+
+	rateLimitClientRate := app.Float64(cli.Float64Opt{
+		Name:   "rate-limit-client-rate",
+		Value:  20,
+		Desc:   "Maximum number of publish requests accepted every second from a client, identified by its X-Api-Key or User-Agent header. 0 disables the limit",
+		EnvVar: "RATE_LIMIT_CLIENT_RATE",
+	})
+
+	rateLimitClientBurst := app.Int(cli.IntOpt{
+		Name:   "rate-limit-client-burst",
+		Value:  40,
+		Desc:   "Maximum number of publish requests accepted at once from a client",
+		EnvVar: "RATE_LIMIT_CLIENT_BURST",
+	})
+
+	rateLimitContentRate := app.Float64(cli.Float64Opt{
+		Name:   "rate-limit-content-rate",
+		Value:  1,
+		Desc:   "Maximum number of publish requests accepted every second for a piece of content. 0 disables the limit",
+		EnvVar: "RATE_LIMIT_CONTENT_RATE",
+	})
+
+	rateLimitContentBurst := app.Int(cli.IntOpt{
+		Name:   "rate-limit-content-burst",
+		Value:  5,
+		Desc:   "Maximum number of publish requests accepted at once for a piece of content",
+		EnvVar: "RATE_LIMIT_CONTENT_BURST",
+	})
+
+	tracingExporter := app.String(cli.StringOpt{
+		Name:   "tracing-exporter",
+		Value:  "none",
+		Desc:   "Where OpenTelemetry spans are exported to: otlp, stdout or none. Trace context headers are propagated to downstream services regardless",
+		EnvVar: "TRACING_EXPORTER",
+	})
+
+	tracingOTLPEndpoint := app.String(cli.StringOpt{
+		Name:   "tracing-otlp-endpoint",
+		Value:  "",
+		Desc:   "Host and port of the OTLP HTTP collector spans are exported to with the otlp exporter. The OTEL_EXPORTER_OTLP_* environment variables are used if empty",
+		EnvVar: "TRACING_OTLP_ENDPOINT",
+	})
+
+	tracingFile := app.String(cli.StringOpt{
+		Name:   "tracing-file",
+		Value:  "",
+		Desc:   "File spans are appended to with the stdout exporter, one JSON object per span. Spans are written to stdout if empty",
+		EnvVar: "TRACING_FILE",
+	})
+
+	tracingSampleRatio := app.Float64(cli.Float64Opt{
+		Name:   "tracing-sample-ratio",
+		Value:  1,
+		Desc:   "Fraction of the traces started by this service which are exported. Traces started by callers are exported if the caller exported them",
+		EnvVar: "TRACING_SAMPLE_RATIO",
+	})
+
+	propagatedHeaders := app.Strings(cli.StringsOpt{
+		Name:   "propagated-headers",
+		Value:  []string{},
+		Desc:   "Headers of incoming requests which are sent on to the downstream services, besides X-Request-Id and X-User-Id. Credentials such as X-Api-Key and Authorization should not be listed",
+		EnvVar: "PROPAGATED_HEADERS",
+	})
+
+	authMethods := app.Strings(cli.StringsOpt{
+		Name:   "auth-methods",
+		Value:  []string{},
+		Desc:   "How callers of the publish endpoints are authenticated: any of api-key, hmac and jwt. The endpoints are open to anyone if empty",
+		EnvVar: "AUTH_METHODS",
+	})
+
+	authClientsFile := app.String(cli.StringOpt{
+		Name:   "auth-clients-file",
+		Value:  "",
+		Desc:   "Location of the YAML file holding the API keys, HMAC secrets and scopes of the clients, required by the api-key and hmac auth methods",
+		EnvVar: "AUTH_CLIENTS_FILE",
+	})
+
+	authHMACMaxSkew := app.String(cli.StringOpt{
+		Name:   "auth-hmac-max-skew",
+		Value:  "5m",
+		Desc:   "How long before or after it is received a request can be signed with the hmac auth method",
+		EnvVar: "AUTH_HMAC_MAX_SKEW",
+	})
+
+	authJWKSFile := app.String(cli.StringOpt{
+		Name:   "auth-jwks-file",
+		Value:  "",
+		Desc:   "Location of the JWKS file holding the keys which sign the JWTs of the jwt auth method",
+		EnvVar: "AUTH_JWKS_FILE",
+	})
+
+	authJWTIssuer := app.String(cli.StringOpt{
+		Name:   "auth-jwt-issuer",
+		Value:  "",
+		Desc:   "Issuer required of JWTs. The issuer is not checked if empty",
+		EnvVar: "AUTH_JWT_ISSUER",
+	})
+
+	authJWTAudience := app.String(cli.StringOpt{
+		Name:   "auth-jwt-audience",
+		Value:  "",
+		Desc:   "Audience required of JWTs. The audience is not checked if empty",
+		EnvVar: "AUTH_JWT_AUDIENCE",
+	})
+
 	log := logger.NewUPPInfoLogger(*appName)
 
-	app.Action = func() {
-		log.Infof("System code: %s, App Name: %s, Port: %s", *appSystemCode, *appName, *port)
-		timeout, err := time.ParseDuration(*httpTimeout)
+	// setupTracing installs the span exporter, and returns the function which flushes the spans not exported yet
+	setupTracing := func() func() {
+		shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+			ServiceName:  *appSystemCode,
+			Exporter:     *tracingExporter,
+			OTLPEndpoint: *tracingOTLPEndpoint,
+			File:         *tracingFile,
+			SampleRatio:  *tracingSampleRatio,
+		})
 		if err != nil {
-			log.WithError(err).Fatal("Provided http timeout is not in the standard duration format.")
+			log.WithError(err).Fatal("Failed to set up tracing.")
+		}
+		return func() {
+			if err := shutdown(context.Background()); err != nil {
+				log.WithError(err).Warn("Failed to export the remaining spans.")
+			}
 		}
+	}
 
-		httpClient, err := fthttp.NewClient(
-			fthttp.WithSysInfo("PAC", *appSystemCode),
-			fthttp.WithTimeout(timeout),
-		)
-		if err != nil {
-			log.WithError(err).Fatal("Failed to create new http client.")
+	// newPublisher creates the publisher and the clients of the downstream services, which are shared by the server and the republish command
+	newPublisher := func(timeout time.Duration, options ...annotations.PublisherOption) publisherComponents {
+		c := publisherComponents{metrics: telemetry.NewMetrics()}
+		openTimeout := parseDuration(*circuitBreakerOpenTimeout, "circuit breaker open timeout", log)
+		bulkheadLimits := bulkhead.Limits{
+			MaxConcurrent: *bulkheadMaxConcurrent,
+			MaxQueued:     *bulkheadMaxQueued,
+			WaitTimeout:   parseDuration(*bulkheadWaitTimeout, "bulkhead wait timeout", log),
+		}
+		// the transaction ID, origin system and caller of every request are sent to all the downstream services
+		c.propagator = outbound.NewPropagator(*originSystemID, *propagatedHeaders)
+		// every downstream service has its own client, so that an open circuit breaker or a full bulkhead only rejects requests to the failing service
+		newHTTPClient := func(downstream string) *http.Client {
+			client, err := fthttp.NewClient(
+				fthttp.WithSysInfo("PAC", *appSystemCode),
+				fthttp.WithTimeout(timeout),
+				c.propagator.ClientOption(),
+			)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to create new http client.")
+			}
+			client.Transport = tracing.Transport(client.Transport)
+			// responses are counted before the breaker and the bulkhead, so only requests which reached the service are counted
+			client.Transport = c.metrics.Transport(downstream, client.Transport)
+			if *circuitBreakerThreshold > 0 {
+				cb := breaker.New(downstream, *circuitBreakerThreshold, openTimeout)
+				client.Transport = cb.Transport(client.Transport)
+				c.breakers = append(c.breakers, cb)
+			}
+			// the bulkhead wraps the breaker, so its rejections are not counted as failures of the service
+			if bulkheadLimits.MaxConcurrent > 0 {
+				bh := bulkhead.New(downstream, bulkheadLimits)
+				client.Transport = bh.Transport(client.Transport)
+				if err := bh.RegisterMetrics(metrics.DefaultRegistry); err != nil {
+					log.WithError(err).Warnf("Failed to register metrics of the bulkhead for %v.", downstream)
+				}
+			}
+			return client
 		}
 
-		draftAnnotationsRW, err := annotations.NewAnnotationsClient(*draftsEndpoint, httpClient, log)
+		draftAnnotationsRW, err := annotations.NewAnnotationsClient(*draftsEndpoint, newHTTPClient("draft-annotations-rw"), log)
 		if err != nil {
 			log.WithError(err).Fatal("Failed to create new draft annotations writer.")
 		}
 
-		publishedAnnotationsRW, err := annotations.NewAnnotationsClient(*writerEndpoint, httpClient, log)
+		publishedAnnotationsRW, err := annotations.NewAnnotationsClient(*writerEndpoint, newHTTPClient("published-annotations-rw"), log)
 		if err != nil {
 			log.WithError(err).Fatal("Failed to create new published annotations writer.")
 		}
 
-		publisher := annotations.NewPublisher(*originSystemID, draftAnnotationsRW, publishedAnnotationsRW, *annotationsEndpoint, *annotationsAuth, *annotationsGTGEndpoint, httpClient, log)
-		healthService := health.NewHealthService(*appSystemCode, *appName, appDescription, publisher, publishedAnnotationsRW, draftAnnotationsRW)
+		rwRetryPolicy := annotations.RetryPolicy{
+			MaxAttempts:          *rwRetryMaxAttempts,
+			InitialBackoff:       parseDuration(*rwRetryInitialBackoff, "r/w retry initial backoff", log),
+			MaxBackoff:           parseDuration(*rwRetryMaxBackoff, "r/w retry max backoff", log),
+			Jitter:               *rwRetryJitter,
+			RetryableStatusCodes: *rwRetryStatusCodes,
+		}
+		draftAnnotationsRW = annotations.NewRetryingAnnotationsClient(draftAnnotationsRW, rwRetryPolicy, log)
+		publishedAnnotationsRW = annotations.NewRetryingAnnotationsClient(publishedAnnotationsRW, rwRetryPolicy, log)
+
+		// publishes are only serialised within this instance
+		c.locker = annotations.NewKeyedLocker()
+		publisherOptions := []annotations.PublisherOption{annotations.WithPublishLock(c.locker)}
+		if *transactionalPublish {
+			publisherOptions = append(publisherOptions, annotations.WithTransactionalPublish())
+		}
+		if *mergeBaseVersions > 0 {
+			draftVersions := annotations.NewVersionRecordingClient(draftAnnotationsRW, *mergeBaseVersions)
+			draftAnnotationsRW = draftVersions
+			publisherOptions = append(publisherOptions, annotations.WithMerge(draftVersions))
+		}
+
+		if *validateAnnotations {
+			publisherOptions = append(publisherOptions, annotations.WithValidation(annotations.NewSchemaValidator(*annotationPredicates, *conceptTypes)))
+		}
+		if *annotationRules != "" {
+			rules, err := annotations.LoadRulesValidator(*annotationRules)
+			if err != nil {
+				log.WithError(err).WithField("file", *annotationRules).Fatal("Failed to load annotation rules.")
+			}
+			publisherOptions = append(publisherOptions, annotations.WithValidation(rules))
+		}
+
+		switch *publishHistoryStore {
+		case "memory":
+			c.publishHistory = audit.NewMemoryStore(*publishHistoryMaxRecords, *publishHistoryMaxContent)
+		case "file":
+			c.publishHistory, err = audit.NewFileStore(*publishHistoryDir)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to create publish history store.")
+			}
+		case "none":
+		default:
+			log.Fatalf("Unknown publish history store %v.", *publishHistoryStore)
+		}
+		if c.publishHistory != nil {
+			publisherOptions = append(publisherOptions, annotations.WithPublishAuditor(audit.NewRecorder(c.publishHistory)))
+		}
+
+		publisherOptions = append(publisherOptions, annotations.WithPublishObserver(c.metrics))
+		publisherOptions = append(publisherOptions, options...)
+		c.publisher = annotations.NewPublisher(*originSystemID, draftAnnotationsRW, publishedAnnotationsRW, *annotationsEndpoint, *annotationsAuth, *annotationsGTGEndpoint, newHTTPClient("upp-publish"), log, publisherOptions...)
+		c.draftAnnotationsRW = draftAnnotationsRW
+		c.publishedAnnotationsRW = publishedAnnotationsRW
+		return c
+	}
+
+	app.Action = func() {
+		log.Infof("System code: %s, App Name: %s, Port: %s", *appSystemCode, *appName, *port)
+		timeout, err := time.ParseDuration(*httpTimeout)
+		if err != nil {
+			log.WithError(err).Fatal("Provided http timeout is not in the standard duration format.")
+		}
+		stopTracing := setupTracing()
+		defer stopTracing()
+
+		var publisherOptions []annotations.PublisherOption
+		var publishRetries *retryqueue.Queue
+		if *publishRetryDir != "" {
+			store, err := retryqueue.NewFileStore(*publishRetryDir)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to create publish retry store.")
+			}
+
+			policy := retryqueue.Policy{
+				InitialBackoff: parseDuration(*publishRetryInitialBackoff, "publish retry initial backoff", log),
+				MaxBackoff:     parseDuration(*publishRetryMaxBackoff, "publish retry max backoff", log),
+				MaxAttempts:    *publishRetryMaxAttempts,
+				Timeout:        timeout,
+			}
+			publishRetries = retryqueue.NewQueue(store, policy, log)
+			publisherOptions = append(publisherOptions, annotations.WithFailedPublishQueue(publishRetries))
+		}
+
+		c := newPublisher(timeout, publisherOptions...)
+		publisher := c.publisher
+		if publishRetries != nil {
+			publishRetries.Start(publisher, c.locker, parseDuration(*publishRetryInterval, "publish retry interval", log))
+		}
+		healthService := health.NewHealthService(*appSystemCode, *appName, appDescription, publisher, c.publishedAnnotationsRW, c.draftAnnotationsRW, c.breakers...)
+
+		publishJobs := jobs.NewQueue(publisher, *publishJobQueueSize, timeout, parseDuration(*publishJobRetention, "publish job retention", log), log)
+		publishJobs.Start(*publishJobWorkers)
 
-		serveEndpoints(*port, apiYml, publisher, healthService, timeout, log)
+		var idempotencyKeys *idempotency.Cache
+		if window := parseDuration(*idempotencyWindow, "idempotency window", log); window > 0 {
+			idempotencyKeys = idempotency.NewCache(window)
+		}
+
+		rateLimiter := ratelimit.NewLimiter(
+			ratelimit.Limit{Rate: *rateLimitGlobalRate, Burst: *rateLimitGlobalBurst},
+			ratelimit.Limit{Rate: *rateLimitClientRate, Burst: *rateLimitClientBurst},
+			ratelimit.Limit{Rate: *rateLimitContentRate, Burst: *rateLimitContentBurst},
+		)
+
+		var authenticator auth.Authenticator
+		if len(*authMethods) > 0 {
+			authenticator = newAuthenticator(*authMethods, *authClientsFile, parseDuration(*authHMACMaxSkew, "HMAC max skew", log), *authJWKSFile, *authJWTIssuer, *authJWTAudience, log)
+		} else {
+			log.Warn("Authentication is disabled, the publish endpoints are open to anyone who can reach them.")
+		}
+
+		serveEndpoints(*port, apiYml, publisher, publishJobs, publishRetries, c.publishHistory, idempotencyKeys, rateLimiter, authenticator, c.metrics, c.propagator, healthService, timeout, *batchConcurrency, *batchMaxItems, log)
 	}
 
+	app.Command("republish", "Republish the annotations of every UUID read from a file, one per line, to UPP", func(cmd *cli.Cmd) {
+		input := cmd.String(cli.StringOpt{
+			Name:  "input",
+			Value: "-",
+			Desc:  "File of the UUIDs to republish, one per line. The UUIDs are read from stdin if -",
+		})
+
+		concurrency := cmd.Int(cli.IntOpt{
+			Name:  "concurrency",
+			Value: 4,
+			Desc:  "Maximum number of republishes run in parallel",
+		})
+
+		rate := cmd.Float64(cli.Float64Opt{
+			Name:  "rate",
+			Value: 10,
+			Desc:  "Maximum number of republishes started every second. There is no limit if 0",
+		})
+
+		checkpoint := cmd.String(cli.StringOpt{
+			Name:  "checkpoint",
+			Value: "",
+			Desc:  "File where the republished UUIDs are recorded, so they are skipped when the command is run again. Nothing is recorded if empty",
+		})
+
+		report := cmd.String(cli.StringOpt{
+			Name:  "report",
+			Value: "./republish-report.jsonl",
+			Desc:  "File the outcome of every republish is appended to, as CSV if it ends in .csv and as JSON lines otherwise",
+		})
+
+		fromStore := cmd.Bool(cli.BoolOpt{
+			Name:  "from-store",
+			Value: false,
+			Desc:  "Publish the draft annotations instead of republishing the published annotations",
+		})
+
+		cmd.Action = func() {
+			// the command exits non-zero when it fails, after the report, the checkpoint and the spans have been flushed
+			failed := false
+			defer func() {
+				if failed {
+					cli.Exit(1)
+				}
+			}()
+
+			timeout := parseDuration(*httpTimeout, "http timeout", log)
+			stopTracing := setupTracing()
+			defer stopTracing()
+			publisher := newPublisher(timeout).publisher
+			publish := publisher.Republish
+			if *fromStore {
+				publish = publisher.PublishFromStore
+			}
+
+			uuids := os.Stdin
+			if *input != "-" {
+				f, err := os.Open(*input)
+				if err != nil {
+					log.WithError(err).Fatal("Failed to open the UUIDs to republish.")
+				}
+				defer f.Close()
+				uuids = f
+			}
+
+			progress, err := backfill.OpenCheckpoint(*checkpoint)
+			if err != nil {
+				log.WithError(err).WithField("file", *checkpoint).Fatal("Failed to open checkpoint.")
+			}
+			defer progress.Close()
+
+			results, err := backfill.OpenReport(*report)
+			if err != nil {
+				log.WithError(err).WithField("file", *report).Fatal("Failed to open report.")
+			}
+			defer results.Close()
+
+			// an interrupted republish finishes the publishes in progress, so it can be resumed from the checkpoint
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			opts := backfill.Options{Concurrency: *concurrency, Rate: *rate, Timeout: timeout}
+			summary, err := backfill.Run(ctx, uuids, publish, progress, results, opts, log)
+			mlog := log.WithField("succeeded", summary.Succeeded).WithField("failed", summary.Failed).WithField("skipped", summary.Skipped)
+			if err != nil {
+				mlog.WithError(err).Error("Republish stopped before every UUID was republished.")
+				failed = true
+				return
+			}
+			if summary.Failed > 0 {
+				mlog.Error("Republish finished, but some UUIDs failed to be republished.")
+				failed = true
+				return
+			}
+			mlog.Info("Republish finished.")
+		}
+	})
+
 	err := app.Run(os.Args)
 	if err != nil {
 		log.Errorf("App could not start, error=[%s]\n", err)
@@ -137,17 +739,67 @@ func main() {
 	}
 }
 
-func serveEndpoints(port string, apiYml *string, publisher annotations.Publisher, healthService *health.HealthService, timeout time.Duration, log *logger.UPPLogger) {
+// publisherComponents are the publisher, the clients it uses, which are checked by the healthchecks, and the metrics it reports to
+type publisherComponents struct {
+	publisher              annotations.Publisher
+	draftAnnotationsRW     annotations.AnnotationsClient
+	publishedAnnotationsRW annotations.AnnotationsClient
+	breakers               []health.CircuitBreaker
+	publishHistory         audit.Store
+	metrics                *telemetry.Metrics
+	propagator             *outbound.Propagator
+	// locker serialises the publishes of every piece of content, including the replays of the publish retry queue
+	locker *annotations.KeyedLocker
+}
+
+func serveEndpoints(port string, apiYml *string, publisher annotations.Publisher, publishJobs *jobs.Queue, publishRetries *retryqueue.Queue, publishHistory audit.Store, idempotencyKeys *idempotency.Cache, rateLimiter *ratelimit.Limiter, authenticator auth.Authenticator, publishMetrics *telemetry.Metrics, propagator *outbound.Propagator, healthService *health.HealthService, timeout time.Duration, batchConcurrency int, batchMaxItems int, log *logger.UPPLogger) {
 	r := vestigo.NewRouter()
-	r.Post("/drafts/content/:uuid/annotations/publish", resources.Publish(publisher, timeout, log))
+	publishOptions := []resources.PublishOption{resources.WithPublishJobs(publishJobs)}
+	if idempotencyKeys != nil {
+		publishOptions = append(publishOptions, resources.WithIdempotency(idempotencyKeys))
+	}
+	// only the requests which publish to UPP are rate limited
+	var limited, batchLimited []vestigo.Middleware
+	if rateLimiter != nil {
+		limited = append(limited, resources.RateLimit(rateLimiter, log))
+		batchLimited = append(batchLimited, resources.BatchRateLimit(rateLimiter, log))
+	}
+	// authorized puts the authentication of the caller, if it is enabled, in front of the other middleware of a route
+	authorized := func(scopes func(r *http.Request) []auth.Scope, middleware ...vestigo.Middleware) []vestigo.Middleware {
+		if authenticator == nil {
+			return middleware
+		}
+		return append([]vestigo.Middleware{resources.Authorize(authenticator, scopes, log)}, middleware...)
+	}
+	publishScope := resources.RequireScopes(auth.ScopePublish)
+	adminScope := resources.RequireScopes(auth.ScopeAdmin)
+
+	r.Post("/drafts/content/:uuid/annotations/publish", resources.Publish(publisher, timeout, log, publishOptions...), authorized(resources.PublishScopes, limited...)...)
+	r.Delete("/drafts/content/:uuid/annotations/publish", resources.Unpublish(publisher, timeout, log), authorized(publishScope, limited...)...)
+	r.Get("/drafts/content/:uuid/annotations/diff", resources.UnpublishedChanges(publisher, timeout, log), authorized(publishScope)...)
+	r.Get("/publish-jobs/:id", resources.PublishJob(publishJobs), authorized(publishScope)...)
+
+	if publishRetries != nil {
+		r.Get("/__publish-retries", resources.ListPublishRetries(publishRetries, log), authorized(adminScope)...)
+		r.Post("/__publish-retries/:uuid/retry", resources.RetryPublish(publishRetries, log), authorized(adminScope)...)
+		r.Delete("/__publish-retries/:uuid", resources.DiscardPublishRetry(publishRetries, log), authorized(adminScope)...)
+	}
+	r.Post("/drafts/content/annotations/publish", resources.BatchPublish(publisher, timeout, batchConcurrency, batchMaxItems, log), authorized(resources.RequireScopes(auth.ScopeBatch), batchLimited...)...)
+	r.Post("/content/:uuid/annotations/republish", resources.Republish(publisher, timeout, log), authorized(adminScope, limited...)...)
+	if publishHistory != nil {
+		r.Get("/content/:uuid/annotations/publish-history", resources.PublishHistory(publishHistory, log), authorized(publishScope)...)
+	}
 
 	var monitoringRouter http.Handler = r
+	monitoringRouter = propagator.Handler(monitoringRouter)
 	monitoringRouter = httphandlers.TransactionAwareRequestLoggingHandler(log, monitoringRouter)
 	monitoringRouter = httphandlers.HTTPMetricsHandler(metrics.DefaultRegistry, monitoringRouter)
 
 	r.Get("/__health", healthService.HealthCheckHandleFunc())
 	r.Get(status.GTGPath, status.NewGoodToGoHandler(healthService.GTG))
 	r.Get(status.BuildInfoPath, status.BuildInfoHandler)
+	r.Get("/__metrics", resources.Metrics(metrics.DefaultRegistry))
+	r.Get("/metrics", publishMetrics.Handler().ServeHTTP)
 
 	http.Handle("/", monitoringRouter)
 
@@ -164,3 +816,44 @@ func serveEndpoints(port string, apiYml *string, publisher annotations.Publisher
 		log.Fatalf("Unable to start: %v", err)
 	}
 }
+
+// newAuthenticator returns the Authenticator of the given methods, which are tried in order
+func newAuthenticator(methods []string, clientsFile string, hmacMaxSkew time.Duration, jwksFile string, jwtIssuer string, jwtAudience string, log *logger.UPPLogger) auth.Authenticator {
+	var clients []auth.Client
+	loadClients := func() []auth.Client {
+		if clients == nil {
+			var err error
+			if clients, err = auth.LoadClients(clientsFile); err != nil {
+				log.WithError(err).WithField("file", clientsFile).Fatal("Failed to load auth clients.")
+			}
+		}
+		return clients
+	}
+
+	var authenticators []auth.Authenticator
+	for _, method := range methods {
+		switch method {
+		case "api-key":
+			authenticators = append(authenticators, auth.NewAPIKeyAuthenticator(loadClients()))
+		case "hmac":
+			authenticators = append(authenticators, auth.NewHMACAuthenticator(loadClients(), hmacMaxSkew))
+		case "jwt":
+			jwtAuthenticator, err := auth.LoadJWTAuthenticator(jwksFile, jwtIssuer, jwtAudience)
+			if err != nil {
+				log.WithError(err).WithField("file", jwksFile).Fatal("Failed to load JWKS.")
+			}
+			authenticators = append(authenticators, jwtAuthenticator)
+		default:
+			log.Fatalf("Unknown auth method %v.", method)
+		}
+	}
+	return auth.Chain(authenticators...)
+}
+
+func parseDuration(value string, name string, log *logger.UPPLogger) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.WithError(err).Fatalf("Provided %s is not in the standard duration format.", name)
+	}
+	return d
+}
@@ -0,0 +1,108 @@
+package outbound
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Financial-Times/go-ft-http/fthttp"
+	"github.com/Financial-Times/go-ft-http/transport"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+)
+
+const (
+	// OriginSystemIDHeader identifies the system the published annotations come from
+	OriginSystemIDHeader = "X-Origin-System-Id"
+	// ClientIDHeader identifies the authenticated client which made the request. Its credentials are never sent on.
+	ClientIDHeader = "X-Client-Id"
+	// UserIDHeader identifies the user on whose behalf the caller made the request
+	UserIDHeader = "X-User-Id"
+)
+
+type headersKey struct{}
+
+// Propagator sends the transaction ID, the origin system and the headers identifying the caller of a request
+// to the downstream services called while handling it, so their logs can be correlated with ours
+type Propagator struct {
+	originSystemID string
+	// passThrough are the headers of incoming requests which are sent on
+	passThrough []string
+}
+
+// NewPropagator returns a Propagator which sends on the X-User-Id header of incoming requests and the passThrough headers
+func NewPropagator(originSystemID string, passThrough []string) *Propagator {
+	headers := []string{UserIDHeader}
+	for _, h := range passThrough {
+		headers = append(headers, http.CanonicalHeaderKey(h))
+	}
+	return &Propagator{originSystemID: originSystemID, passThrough: headers}
+}
+
+// Handler keeps the headers of every request which are sent on in its context, for next to pass on to the requests it makes
+func (p *Propagator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers := make(http.Header)
+		for _, h := range p.passThrough {
+			if values := r.Header.Values(h); len(values) > 0 {
+				headers[h] = values
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(WithHeaders(r.Context(), headers)))
+	})
+}
+
+// ExtendRequest implements transport.HTTPRequestExtension. Headers which are already set on the request are not replaced.
+func (p *Propagator) ExtendRequest(req *http.Request) {
+	if txid, err := tid.GetTransactionIDFromContext(req.Context()); err == nil {
+		setIfEmpty(req.Header, tid.TransactionIDHeader, txid)
+	}
+	if p.originSystemID != "" {
+		setIfEmpty(req.Header, OriginSystemIDHeader, p.originSystemID)
+	}
+	for h, values := range Headers(req.Context()) {
+		if req.Header.Get(h) == "" {
+			req.Header[h] = values
+		}
+	}
+}
+
+// ClientOption adds the propagator to the requests of a client created by fthttp.NewClient
+func (p *Propagator) ClientOption() fthttp.Option {
+	return func(c *http.Client) error {
+		tr, ok := c.Transport.(*transport.ExtensibleTransport)
+		if !ok {
+			return fthttp.ErrWrongTransport
+		}
+		tr.AddExtension(p)
+		return nil
+	}
+}
+
+// WithHeaders returns a copy of ctx holding the headers to send on to downstream services
+func WithHeaders(ctx context.Context, headers http.Header) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, headersKey{}, headers)
+}
+
+// WithClientID returns a copy of ctx which also sends the ID of the authenticated client on to downstream services
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	headers := Headers(ctx).Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set(ClientIDHeader, clientID)
+	return WithHeaders(ctx, headers)
+}
+
+// Headers returns the headers held by ctx which are sent on to downstream services
+func Headers(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(headersKey{}).(http.Header)
+	return headers
+}
+
+func setIfEmpty(header http.Header, name string, value string) {
+	if header.Get(name) == "" {
+		header.Set(name, value)
+	}
+}
@@ -0,0 +1,79 @@
+package outbound
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/go-ft-http/fthttp"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagatedHeaders(t *testing.T) {
+	var received http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer downstream.Close()
+
+	p := NewPropagator("http://cmdb.ft.com/systems/pac", []string{"x-editor-session"})
+	client, err := fthttp.NewClient(fthttp.WithSysInfo("PAC", "test-annotations-publisher"), p.ClientOption())
+	require.NoError(t, err)
+
+	handler := p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tid.TransactionAwareContext(r.Context(), "tid_test")
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, downstream.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set(OriginSystemIDHeader, "http://cmdb.ft.com/systems/methode-web-pub")
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/drafts/content/a-valid-uuid/annotations/publish", nil)
+	req.Header.Set("X-Api-Key", "a-key")
+	req.Header.Set(UserIDHeader, "an-editor")
+	req.Header.Set("X-Editor-Session", "a-session")
+	req.Header.Set("Authorization", "Bearer a-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NotNil(t, received)
+	assert.Equal(t, "tid_test", received.Get(tid.TransactionIDHeader))
+	assert.Equal(t, "http://cmdb.ft.com/systems/methode-web-pub", received.Get(OriginSystemIDHeader), "headers set on the request should not be replaced")
+	assert.Equal(t, "an-editor", received.Get(UserIDHeader))
+	assert.Equal(t, "a-session", received.Get("X-Editor-Session"))
+	assert.Empty(t, received.Get("Authorization"), "headers which are not allowed should not be sent on")
+	assert.Empty(t, received.Get("X-Api-Key"), "the credentials of the caller should not be sent on")
+}
+
+func TestPropagatedHeadersWithoutIncomingRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(tid.TransactionAwareContext(context.Background(), "tid_test"))
+
+	NewPropagator("http://cmdb.ft.com/systems/pac", nil).ExtendRequest(req)
+
+	assert.Equal(t, "tid_test", req.Header.Get(tid.TransactionIDHeader))
+	assert.Equal(t, "http://cmdb.ft.com/systems/pac", req.Header.Get(OriginSystemIDHeader))
+	assert.Empty(t, req.Header.Get(UserIDHeader))
+}
+
+func TestWithClientID(t *testing.T) {
+	headers := http.Header{UserIDHeader: []string{"an-editor"}}
+	ctx := WithHeaders(context.Background(), headers)
+
+	withClient := Headers(WithClientID(ctx, "pac-ui"))
+	assert.Equal(t, "pac-ui", withClient.Get(ClientIDHeader))
+	assert.Equal(t, "an-editor", withClient.Get(UserIDHeader))
+	assert.Empty(t, headers.Get(ClientIDHeader), "the headers of the parent context should not change")
+
+	assert.Equal(t, "pac-ui", Headers(WithClientID(context.Background(), "pac-ui")).Get(ClientIDHeader))
+}
+
+func TestClientOptionWrongTransport(t *testing.T) {
+	err := NewPropagator("", nil).ClientOption()(&http.Client{Transport: http.DefaultTransport})
+	assert.Equal(t, fthttp.ErrWrongTransport, err)
+}
@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrLimited occurs when a request is rejected because it exceeds a rate limit
+var ErrLimited = errors.New("rate limit exceeded")
+
+// LimitedError is returned for requests rejected by a rate limit, and reports when the limit will let a request through again
+type LimitedError struct {
+	// Scope is the limit which rejected the request: global, client or content
+	Scope      Scope
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return fmt.Sprintf("%v rate limit exceeded", e.Scope)
+}
+
+// Is makes errors.Is(err, ErrLimited) true for every LimitedError
+func (e *LimitedError) Is(target error) bool {
+	return target == ErrLimited
+}
+
+// Scope is what a rate limit is counted by
+type Scope string
+
+const (
+	// ScopeGlobal counts every request
+	ScopeGlobal Scope = "global"
+	// ScopeClient counts the requests of every client separately
+	ScopeClient Scope = "client"
+	// ScopeContent counts the requests for every piece of content separately
+	ScopeContent Scope = "content"
+)
+
+// Limit is the rate of a token bucket, which lets Burst requests through at once and then Rate requests every second. A zero Rate is unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Limiter enforces a global rate limit, and rate limits for every client and piece of content, on the same requests
+type Limiter struct {
+	global  *keyedLimiter
+	client  *keyedLimiter
+	content *keyedLimiter
+}
+
+// NewLimiter returns a Limiter with the given limits. It returns nil if none of the limits is enabled.
+func NewLimiter(global Limit, client Limit, content Limit) *Limiter {
+	if global.Rate <= 0 && client.Rate <= 0 && content.Rate <= 0 {
+		return nil
+	}
+	return &Limiter{global: newKeyedLimiter(global), client: newKeyedLimiter(client), content: newKeyedLimiter(content)}
+}
+
+// Allow takes a token from the global bucket and from the buckets of the client and the piece of content, unless the uuid is empty.
+// If any bucket is empty no token is taken, and a *LimitedError is returned.
+func (l *Limiter) Allow(client string, uuid string) error {
+	if uuid == "" {
+		return l.AllowMany(client, nil)
+	}
+	return l.AllowMany(client, []string{uuid})
+}
+
+// AllowMany takes a token for every uuid from the global bucket, the bucket of the client and the bucket of the uuid, for a request publishing many pieces of content.
+// A request for more pieces of content than the burst of a limit is let through when its bucket is full, and the tokens it takes beyond the burst delay the next requests.
+// If any bucket has too few tokens no token is taken, and a *LimitedError is returned.
+func (l *Limiter) AllowMany(client string, uuids []string) error {
+	for i, uuid := range uuids {
+		if uuid == "" {
+			continue
+		}
+		if wait, ok := l.content.take(uuid, 1); !ok {
+			l.refundContent(uuids[:i])
+			return &LimitedError{Scope: ScopeContent, RetryAfter: wait}
+		}
+	}
+
+	tokens := len(uuids)
+	if tokens == 0 {
+		tokens = 1
+	}
+	if wait, ok := l.client.take(client, tokens); !ok {
+		l.refundContent(uuids)
+		return &LimitedError{Scope: ScopeClient, RetryAfter: wait}
+	}
+	if wait, ok := l.global.take("", tokens); !ok {
+		l.refundContent(uuids)
+		l.client.refund(client, tokens)
+		return &LimitedError{Scope: ScopeGlobal, RetryAfter: wait}
+	}
+	return nil
+}
+
+func (l *Limiter) refundContent(uuids []string) {
+	for _, uuid := range uuids {
+		if uuid != "" {
+			l.content.refund(uuid, 1)
+		}
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// keyedLimiter keeps a token bucket for every key. Buckets which have refilled are forgotten, as they are the same as a new bucket.
+type keyedLimiter struct {
+	limit Limit
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// newKeyedLimiter returns a keyedLimiter with full buckets for every key
+func newKeyedLimiter(limit Limit) *keyedLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &keyedLimiter{limit: limit, buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// take takes n tokens from the bucket of the key, which may leave it in debt when n is more than the burst.
+// If the bucket has too few tokens, it returns false and how long until it has enough again.
+func (l *keyedLimiter) take(key string, n int) (time.Duration, bool) {
+	if l.limit.Rate <= 0 {
+		return 0, true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+
+	needed := math.Min(float64(n), float64(l.limit.Burst))
+	if b.tokens < needed {
+		return time.Duration((needed - b.tokens) / l.limit.Rate * float64(time.Second)), false
+	}
+	b.tokens -= float64(n)
+	return 0, true
+}
+
+// refund returns the tokens taken for a request which was then rejected by another limit
+func (l *keyedLimiter) refund(key string, n int) {
+	if l.limit.Rate <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(b.tokens+float64(n), float64(l.limit.Burst))
+	}
+}
+
+func (l *keyedLimiter) refill(b *bucket, now time.Time) {
+	b.tokens = math.Min(b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate, float64(l.limit.Burst))
+	b.last = now
+}
+
+// sweep forgets the buckets which have refilled, at most as often as it takes an empty bucket to refill
+func (l *keyedLimiter) sweep(now time.Time) {
+	refillTime := time.Duration(float64(l.limit.Burst) / l.limit.Rate * float64(time.Second))
+	if now.Sub(l.lastSweep) < refillTime {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter(global Limit, client Limit, content Limit) (*Limiter, *testClock) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(global, client, content)
+	l.global.now = clock.Now
+	l.client.now = clock.Now
+	l.content.now = clock.Now
+	return l, clock
+}
+
+func assertLimited(t *testing.T, err error, scope Scope, retryAfter time.Duration) {
+	var limitedErr *LimitedError
+	require.True(t, errors.As(err, &limitedErr), "expected a LimitedError, got %v", err)
+	assert.True(t, errors.Is(err, ErrLimited))
+	assert.Equal(t, scope, limitedErr.Scope)
+	assert.Equal(t, retryAfter, limitedErr.RetryAfter)
+}
+
+func TestNewLimiterWithoutLimits(t *testing.T) {
+	assert.Nil(t, NewLimiter(Limit{}, Limit{}, Limit{}))
+}
+
+func TestLimiterAllowsBurstThenRate(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 2, Burst: 3}, Limit{}, Limit{})
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, l.Allow("client", ""))
+	}
+	assertLimited(t, l.Allow("client", ""), ScopeGlobal, 500*time.Millisecond)
+
+	clock.now = clock.now.Add(250 * time.Millisecond)
+	assertLimited(t, l.Allow("other-client", ""), ScopeGlobal, 250*time.Millisecond)
+
+	clock.now = clock.now.Add(250 * time.Millisecond)
+	require.NoError(t, l.Allow("client", ""))
+	assertLimited(t, l.Allow("client", ""), ScopeGlobal, 500*time.Millisecond)
+}
+
+func TestLimiterCountsClientsSeparately(t *testing.T) {
+	l, _ := newTestLimiter(Limit{}, Limit{Rate: 1, Burst: 1}, Limit{})
+
+	require.NoError(t, l.Allow("client", "a-valid-uuid"))
+	assertLimited(t, l.Allow("client", "another-uuid"), ScopeClient, time.Second)
+	require.NoError(t, l.Allow("other-client", "another-uuid"))
+}
+
+func TestLimiterCountsContentSeparately(t *testing.T) {
+	l, _ := newTestLimiter(Limit{}, Limit{}, Limit{Rate: 1, Burst: 1})
+
+	require.NoError(t, l.Allow("client", "a-valid-uuid"))
+	assertLimited(t, l.Allow("other-client", "a-valid-uuid"), ScopeContent, time.Second)
+	require.NoError(t, l.Allow("client", "another-uuid"))
+
+	// requests which are not for a piece of content are not limited by it
+	require.NoError(t, l.Allow("client", ""))
+	require.NoError(t, l.Allow("client", ""))
+}
+
+func TestLimiterDoesNotTakeTokensOfRejectedRequests(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Rate: 1, Burst: 2}, Limit{Rate: 1, Burst: 1}, Limit{Rate: 1, Burst: 2})
+
+	require.NoError(t, l.Allow("client", "a-valid-uuid"))
+	assertLimited(t, l.Allow("client", "a-valid-uuid"), ScopeClient, time.Second)
+
+	// the rejected request took neither a global nor a content token
+	require.NoError(t, l.Allow("other-client", "a-valid-uuid"))
+	assertLimited(t, l.Allow("third-client", "another-uuid"), ScopeGlobal, time.Second)
+}
+
+func TestLimiterAllowManyTakesATokenForEveryUUID(t *testing.T) {
+	l, clock := newTestLimiter(Limit{}, Limit{Rate: 1, Burst: 3}, Limit{Rate: 1, Burst: 1})
+
+	require.NoError(t, l.AllowMany("client", []string{"uuid-1", "uuid-2"}))
+	assertLimited(t, l.AllowMany("client", []string{"uuid-3", "uuid-4"}), ScopeClient, time.Second)
+	assertLimited(t, l.Allow("other-client", "uuid-2"), ScopeContent, time.Second)
+
+	// the rejected request took no content token
+	require.NoError(t, l.Allow("client", "uuid-3"))
+	assertLimited(t, l.Allow("client", "uuid-4"), ScopeClient, time.Second)
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	assertLimited(t, l.AllowMany("other-client", []string{"uuid-4", "uuid-1"}), ScopeContent, 500*time.Millisecond)
+	require.NoError(t, l.Allow("other-client", "uuid-4"), "the rejected request should have given back the token of uuid-4")
+}
+
+func TestLimiterAllowManyBeyondTheBurst(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 1, Burst: 2}, Limit{}, Limit{})
+
+	require.NoError(t, l.Allow("client", ""))
+	assertLimited(t, l.AllowMany("client", []string{"uuid-1", "uuid-2", "uuid-3", "uuid-4"}), ScopeGlobal, time.Second)
+
+	// a full bucket lets a request through, which leaves it in debt for the tokens beyond the burst
+	clock.now = clock.now.Add(time.Second)
+	require.NoError(t, l.AllowMany("client", []string{"uuid-1", "uuid-2", "uuid-3", "uuid-4"}))
+	assertLimited(t, l.Allow("client", ""), ScopeGlobal, 3*time.Second)
+}
+
+func TestLimiterForgetsRefilledBuckets(t *testing.T) {
+	l, clock := newTestLimiter(Limit{}, Limit{Rate: 1, Burst: 2}, Limit{})
+
+	require.NoError(t, l.Allow("client", ""))
+	require.NoError(t, l.Allow("other-client", ""))
+	assert.Len(t, l.client.buckets, 2)
+
+	clock.now = clock.now.Add(2 * time.Second)
+	require.NoError(t, l.Allow("client", ""))
+	assert.Len(t, l.client.buckets, 1)
+}
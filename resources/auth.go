@@ -0,0 +1,74 @@
+package resources
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Financial-Times/annotations-publisher/auth"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/husobee/vestigo"
+)
+
+// Authorize returns a middleware which responds with a 401 to requests which are not authenticated by the authenticator,
+// and with a 403 to requests whose caller does not hold every scope returned by scopes for the request
+func Authorize(authenticator auth.Authenticator, scopes func(r *http.Request) []auth.Scope, log *logger.UPPLogger) vestigo.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mlog := log.WithTransactionID(tid.GetTransactionIDFromRequest(r)).WithField("path", r.URL.Path)
+
+			identity, err := authenticator.Authenticate(r)
+			switch {
+			case errors.Is(err, auth.ErrNoCredentials):
+				writeMsg(w, http.StatusUnauthorized, "Please provide an API key, a signature or a token")
+				return
+			case errors.Is(err, auth.ErrInvalidCredentials):
+				mlog.WithError(err).Warn("request rejected with invalid credentials")
+				writeMsg(w, http.StatusUnauthorized, "The provided credentials are not valid")
+				return
+			case err != nil:
+				mlog.WithError(err).Warn("error reading body")
+				writeMsg(w, http.StatusBadRequest, "Failed to read request body. Please provide a valid json request body")
+				return
+			}
+
+			if scope, missing := missingScope(identity, scopes(r)); missing {
+				mlog.WithField("client", identity.Client).WithField("scope", scope).Warn("request rejected without the required scope")
+				writeMsg(w, http.StatusForbidden, "The caller does not have the "+string(scope)+" scope required by this request")
+				return
+			}
+			next(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+		}
+	}
+}
+
+// RequireScopes returns the scopes function of Authorize for requests which always require the given scopes
+func RequireScopes(scopes ...auth.Scope) func(r *http.Request) []auth.Scope {
+	return func(*http.Request) []auth.Scope {
+		return scopes
+	}
+}
+
+// PublishScopes are the scopes required by a publish, which also requires the fromStore scope for a publish with fromStore=true
+func PublishScopes(r *http.Request) []auth.Scope {
+	fromStore, _ := strconv.ParseBool(r.URL.Query().Get("fromStore"))
+	return publishScopes(fromStore)
+}
+
+func publishScopes(fromStore bool) []auth.Scope {
+	if fromStore {
+		return []auth.Scope{auth.ScopePublish, auth.ScopeFromStore}
+	}
+	return []auth.Scope{auth.ScopePublish}
+}
+
+// missingScope returns the first of the scopes which the identity does not hold
+func missingScope(identity auth.Identity, scopes []auth.Scope) (auth.Scope, bool) {
+	for _, scope := range scopes {
+		if !identity.HasScope(scope) {
+			return scope, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,254 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/auth"
+	"github.com/Financial-Times/annotations-publisher/idempotency"
+	"github.com/Financial-Times/annotations-publisher/outbound"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// testAuthenticator authenticates requests by their X-Api-Key header
+type testAuthenticator map[string]auth.Identity
+
+func (a testAuthenticator) Authenticate(r *http.Request) (auth.Identity, error) {
+	key := r.Header.Get(auth.APIKeyHeader)
+	if key == "" {
+		return auth.Identity{}, auth.ErrNoCredentials
+	}
+	identity, ok := a[key]
+	if !ok {
+		return auth.Identity{}, auth.ErrInvalidCredentials
+	}
+	return identity, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	authenticator := testAuthenticator{
+		"publisher":  {Client: "pac-ui", Scopes: []auth.Scope{auth.ScopePublish}},
+		"from-store": {Client: "pac-ui", Scopes: []auth.Scope{auth.ScopePublish, auth.ScopeFromStore}},
+		"admin":      {Client: "ops", Scopes: []auth.Scope{auth.ScopeAdmin}},
+	}
+	r := vestigo.NewRouter()
+	r.Post("/drafts/content/:uuid/annotations/publish", func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := auth.IdentityFromContext(r.Context())
+		require.True(t, ok)
+		writeMsg(w, http.StatusAccepted, "Publish accepted for "+identity.Client)
+	}, Authorize(authenticator, PublishScopes, logger.NewUPPLogger("test", "DEBUG")))
+
+	tests := []struct {
+		name           string
+		apiKey         string
+		query          string
+		expectedStatus int
+		expectedMsg    string
+	}{
+		{
+			name:           "no credentials",
+			expectedStatus: http.StatusUnauthorized,
+			expectedMsg:    "Please provide an API key, a signature or a token",
+		},
+		{
+			name:           "invalid credentials",
+			apiKey:         "unknown",
+			expectedStatus: http.StatusUnauthorized,
+			expectedMsg:    "The provided credentials are not valid",
+		},
+		{
+			name:           "publish scope",
+			apiKey:         "publisher",
+			expectedStatus: http.StatusAccepted,
+			expectedMsg:    "Publish accepted for pac-ui",
+		},
+		{
+			name:           "publish from store without fromStore scope",
+			apiKey:         "publisher",
+			query:          "?fromStore=true",
+			expectedStatus: http.StatusForbidden,
+			expectedMsg:    "The caller does not have the fromStore scope required by this request",
+		},
+		{
+			name:           "publish from store with fromStore scope",
+			apiKey:         "from-store",
+			query:          "?fromStore=true",
+			expectedStatus: http.StatusAccepted,
+			expectedMsg:    "Publish accepted for pac-ui",
+		},
+		{
+			name:           "admin scope grants every scope",
+			apiKey:         "admin",
+			query:          "?fromStore=true",
+			expectedStatus: http.StatusAccepted,
+			expectedMsg:    "Publish accepted for ops",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish"+test.query, nil)
+			if test.apiKey != "" {
+				req.Header.Set(auth.APIKeyHeader, test.apiKey)
+			}
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatus, w.Code)
+			resp, err := marshal(w.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedMsg, resp["message"])
+		})
+	}
+}
+
+func TestPublishEditorIsUserOfToken(t *testing.T) {
+	authenticator := testAuthenticator{"token": {Client: "jane.doe", User: "jane.doe", Scopes: []auth.Scope{auth.ScopePublish}}}
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	pub.On("SaveAndPublish", mock.MatchedBy(func(ctx context.Context) bool {
+		return annotations.EditorFromContext(ctx) == "jane.doe" && outbound.Headers(ctx).Get(outbound.ClientIDHeader) == "jane.doe"
+	}), "a-valid-uuid", "hash", mock.Anything).Return("new-hash", nil)
+
+	log := logger.NewUPPLogger("test", "DEBUG")
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, log), Authorize(authenticator, PublishScopes, log))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish", strings.NewReader(testPublishBody))
+	req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+	req.Header.Set(auth.APIKeyHeader, "token")
+	// the user of a token cannot be impersonated
+	req.Header.Set("X-User-Id", "someone-else")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	pub.AssertExpectations(t)
+}
+
+func TestBatchPublishChecksScopesOfItems(t *testing.T) {
+	authenticator := testAuthenticator{
+		"batch-only":    {Client: "backfill", Scopes: []auth.Scope{auth.ScopeBatch}},
+		"batch-publish": {Client: "backfill", Scopes: []auth.Scope{auth.ScopeBatch, auth.ScopePublish}},
+	}
+	pub := &mockPublisher{}
+	pub.On("SaveAndPublish", mock.Anything, "uuid-with-body", "hash", mock.Anything).Return("hash-with-body", nil)
+
+	log := logger.NewUPPLogger("test", "DEBUG")
+	r := vestigo.NewRouter()
+	r.Post("/drafts/content/annotations/publish", BatchPublish(pub, timeout, 2, 10, log), Authorize(authenticator, RequireScopes(auth.ScopeBatch), log))
+
+	body := `{"items":[
+		{"uuid": "uuid-from-store", "fromStore": true},
+		{"uuid": "uuid-with-body", "previousHash": "hash", "body": {"annotations": [{"predicate": "http://www.ft.com/ontology/annotation/mentions", "id": "http://www.ft.com/thing/0a619d71-9af5-3755-90dd-f789b686c67a"}]}}
+	]}`
+	publish := func(apiKey string) map[string]BatchPublishResult {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/drafts/content/annotations/publish", strings.NewReader(body))
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Results map[string]BatchPublishResult `json:"results"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp.Results
+	}
+
+	results := publish("batch-only")
+	assert.Equal(t, BatchPublishResult{Status: http.StatusForbidden, Error: "forbidden", Message: "The caller does not have the publish scope required by this item"}, results["uuid-from-store"])
+	assert.Equal(t, BatchPublishResult{Status: http.StatusForbidden, Error: "forbidden", Message: "The caller does not have the publish scope required by this item"}, results["uuid-with-body"])
+	pub.AssertNotCalled(t, "SaveAndPublish", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	results = publish("batch-publish")
+	assert.Equal(t, BatchPublishResult{Status: http.StatusForbidden, Error: "forbidden", Message: "The caller does not have the fromStore scope required by this item"}, results["uuid-from-store"])
+	assert.Equal(t, http.StatusAccepted, results["uuid-with-body"].Status)
+	pub.AssertExpectations(t)
+	pub.AssertNotCalled(t, "PublishFromStore", mock.Anything, mock.Anything)
+}
+
+func TestPublishIdempotencyKeysOfDifferentClients(t *testing.T) {
+	authenticator := testAuthenticator{
+		"pac-ui":   {Client: "pac-ui", Scopes: []auth.Scope{auth.ScopePublish}},
+		"backfill": {Client: "backfill", Scopes: []auth.Scope{auth.ScopePublish}},
+	}
+	pub := &mockPublisher{}
+	pub.On("SaveAndPublish", mock.Anything, "a-valid-uuid", "hash", mock.Anything).Return("new-hash", nil).Once()
+	pub.On("SaveAndPublish", mock.Anything, "a-valid-uuid", "other-hash", mock.Anything).Return("other-new-hash", nil).Once()
+
+	log := logger.NewUPPLogger("test", "DEBUG")
+	r := vestigo.NewRouter()
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, log, WithIdempotency(idempotency.NewCache(time.Minute))), Authorize(authenticator, PublishScopes, log))
+
+	publish := func(apiKey string, hash string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish", strings.NewReader(testPublishBody))
+		req.Header.Add(annotations.PreviousDocumentHashHeader, hash)
+		req.Header.Add(IdempotencyKeyHeader, "key")
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := publish("pac-ui", "hash")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "new-hash", w.Header().Get(annotations.DocumentHashHeader))
+
+	// the same key of another client is a different request, which is neither replayed nor rejected
+	w = publish("backfill", "other-hash")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "other-new-hash", w.Header().Get(annotations.DocumentHashHeader))
+	assert.Empty(t, w.Header().Get(IdempotentReplayHeader))
+
+	w = publish("pac-ui", "hash")
+	assert.Equal(t, "new-hash", w.Header().Get(annotations.DocumentHashHeader))
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayHeader))
+
+	pub.AssertExpectations(t)
+}
+
+func TestBatchPublishRejectsItemsAfterPublishedItems(t *testing.T) {
+	authenticator := testAuthenticator{"batch-publish": {Client: "backfill", Scopes: []auth.Scope{auth.ScopeBatch, auth.ScopePublish}}}
+	pub := &mockPublisher{}
+	pub.On("SaveAndPublish", mock.Anything, mock.Anything, "hash", mock.Anything).Return("new-hash", nil)
+
+	log := logger.NewUPPLogger("test", "DEBUG")
+	r := vestigo.NewRouter()
+	r.Post("/drafts/content/annotations/publish", BatchPublish(pub, timeout, 4, 10, log), Authorize(authenticator, RequireScopes(auth.ScopeBatch), log))
+
+	items := make([]string, 0, 6)
+	for i := 0; i < 3; i++ {
+		items = append(items, fmt.Sprintf(`{"uuid": "uuid-with-body-%d", "previousHash": "hash", "body": {"annotations": [{"predicate": "http://www.ft.com/ontology/annotation/mentions", "id": "http://www.ft.com/thing/0a619d71-9af5-3755-90dd-f789b686c67a"}]}}`, i))
+	}
+	for i := 0; i < 3; i++ {
+		items = append(items, fmt.Sprintf(`{"uuid": "uuid-from-store-%d", "fromStore": true}`, i))
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/annotations/publish", strings.NewReader(`{"items":[`+strings.Join(items, ",")+`]}`))
+	req.Header.Set(auth.APIKeyHeader, "batch-publish")
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Results map[string]BatchPublishResult `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Results, 6)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusAccepted, resp.Results[fmt.Sprintf("uuid-with-body-%d", i)].Status)
+		assert.Equal(t, http.StatusForbidden, resp.Results[fmt.Sprintf("uuid-from-store-%d", i)].Status)
+	}
+}
@@ -0,0 +1,179 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/auth"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+)
+
+// BatchPublishRequest is the body accepted by the batch publish endpoint
+type BatchPublishRequest struct {
+	Items []BatchPublishItem `json:"items"`
+}
+
+// BatchPublishItem describes the publish of a single piece of content within a batch.
+// Either Body or FromStore=true must be provided. Merge=true merges Body with concurrent changes to the draft annotations.
+type BatchPublishItem struct {
+	UUID         string                       `json:"uuid"`
+	FromStore    bool                         `json:"fromStore,omitempty"`
+	Merge        bool                         `json:"merge,omitempty"`
+	PreviousHash string                       `json:"previousHash,omitempty"`
+	Body         *annotations.AnnotationsBody `json:"body,omitempty"`
+}
+
+// BatchPublishResult is the outcome of publishing a single piece of content within a batch
+type BatchPublishResult struct {
+	Status  int    `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message"`
+	// DocumentHash is the hash of the published annotations, or of the current draft annotations after a conflict
+	DocumentHash string `json:"documentHash,omitempty"`
+	Rollback     string `json:"rollback,omitempty"`
+	// Annotations are the current draft annotations after a conflict
+	Annotations []annotations.Annotation `json:"annotations,omitempty"`
+	// Conflicts are the annotations which could not be merged
+	Conflicts []annotations.MergeConflict `json:"conflicts,omitempty"`
+	// Errors are the invalid fields of annotations rejected by validation
+	Errors []annotations.FieldError `json:"errors,omitempty"`
+}
+
+// BatchPublish publishes the annotations of many pieces of content, running at most concurrency publishes at a time.
+// It responds with a result for every UUID in the batch. When the caller is authenticated, items needing a scope the caller does not hold are rejected with a 403 result.
+func BatchPublish(publisher annotations.Publisher, httpTimeOut time.Duration, concurrency int, maxItems int, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		txid := tid.GetTransactionIDFromRequest(r)
+		mlog := log.WithField(tid.TransactionIDHeader, txid)
+
+		var batch BatchPublishRequest
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			mlog.WithField("reason", err).Warn("failed to unmarshal batch publish body")
+			writeMsg(w, http.StatusBadRequest, "Failed to process request json. Please provide a valid json request body")
+			return
+		}
+
+		if len(batch.Items) == 0 {
+			writeMsg(w, http.StatusBadRequest, "Please provide at least one item to publish")
+			return
+		}
+		if maxItems > 0 && len(batch.Items) > maxItems {
+			writeMsg(w, http.StatusBadRequest, fmt.Sprintf("A batch cannot contain more than %d items", maxItems))
+			return
+		}
+		if msg := validateBatch(batch); msg != "" {
+			writeMsg(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		mlog.WithField("items", len(batch.Items)).Info("batch publish")
+
+		results := make(map[string]BatchPublishResult, len(batch.Items))
+		var mutex sync.Mutex
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+
+		identity, authenticated := auth.IdentityFromContext(r.Context())
+		for _, item := range batch.Items {
+			if authenticated {
+				// every item needs the scopes of the equivalent single publish
+				if scope, missing := missingScope(identity, publishScopes(item.FromStore)); missing {
+					mlog.WithField("client", identity.Client).WithField("scope", scope).WithUUID(item.UUID).Warn("batch item rejected without the required scope")
+					mutex.Lock()
+					results[item.UUID] = BatchPublishResult{Status: http.StatusForbidden, Error: "forbidden", Message: "The caller does not have the " + string(scope) + " scope required by this item"}
+					mutex.Unlock()
+					continue
+				}
+			}
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(item BatchPublishItem) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+
+				ctx, cancel := context.WithTimeout(requestContext(r, txid), httpTimeOut)
+				defer cancel()
+
+				result := publishItem(ctx, publisher, item, log)
+
+				mutex.Lock()
+				results[item.UUID] = result
+				mutex.Unlock()
+			}(item)
+		}
+		wg.Wait()
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+	}
+}
+
+func validateBatch(batch BatchPublishRequest) string {
+	seen := make(map[string]bool, len(batch.Items))
+	for i, item := range batch.Items {
+		if item.UUID == "" {
+			return fmt.Sprintf("Please specify a valid uuid for item %d", i)
+		}
+		if seen[item.UUID] {
+			return fmt.Sprintf("UUID %v is present more than once in the batch", item.UUID)
+		}
+		seen[item.UUID] = true
+
+		if item.FromStore && item.Merge {
+			return fmt.Sprintf("Merging cannot be requested when fromStore=true for %v", item.UUID)
+		}
+		if item.FromStore && item.Body != nil {
+			return fmt.Sprintf("A body cannot be provided when fromStore=true for %v", item.UUID)
+		}
+		if !item.FromStore && (item.Body == nil || len(item.Body.Annotations) == 0) {
+			return fmt.Sprintf("Please provide a valid body for %v", item.UUID)
+		}
+	}
+	return ""
+}
+
+func publishItem(ctx context.Context, publisher annotations.Publisher, item BatchPublishItem, log *logger.UPPLogger) BatchPublishResult {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	mlog := log.WithFields(map[string]interface{}{"transaction_id": txid, "uuid": item.UUID, "fromStore": item.FromStore})
+
+	var hash string
+	var err error
+	if item.FromStore {
+		hash, err = publisher.PublishFromStore(ctx, item.UUID)
+	} else if item.Merge {
+		hash, err = publisher.MergeAndPublish(ctx, item.UUID, item.PreviousHash, *item.Body)
+	} else {
+		hash, err = publisher.SaveAndPublish(ctx, item.UUID, item.PreviousHash, *item.Body)
+	}
+
+	if err == nil {
+		return BatchPublishResult{Status: http.StatusAccepted, Message: "Publish accepted", DocumentHash: hash}
+	}
+
+	rollback := string(rollbackOutcome(err))
+	if status, category, ok := knownPublishError(err); ok {
+		result := BatchPublishResult{Status: status, Error: category, Message: err.Error(), Rollback: rollback}
+		if current, hash := currentDraft(err); current != nil {
+			result.Annotations = current.Annotations
+			result.DocumentHash = hash
+		}
+		result.Conflicts = mergeConflicts(err)
+		result.Errors = fieldErrors(err)
+		return result
+	}
+
+	mlog.WithError(err).Error("failed to publish annotations in batch")
+	return BatchPublishResult{Status: http.StatusServiceUnavailable, Error: "publish-failed", Message: err.Error(), Rollback: rollback}
+}
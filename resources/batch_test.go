@@ -0,0 +1,141 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testBatchPublishBody = `
+{
+	"items":[
+		{
+			"uuid": "uuid-from-store",
+			"fromStore": true
+		},
+		{
+			"uuid": "uuid-with-body",
+			"previousHash": "hash",
+			"body": {
+				"annotations":[
+					{
+						"predicate": "http://www.ft.com/ontology/annotation/mentions",
+						"id": "http://www.ft.com/thing/0a619d71-9af5-3755-90dd-f789b686c67a"
+					}
+				]
+			}
+		},
+		{
+			"uuid": "uuid-not-found",
+			"fromStore": true
+		},
+		{
+			"uuid": "uuid-failing",
+			"fromStore": true
+		},
+		{
+			"uuid": "uuid-conflict",
+			"fromStore": true
+		}
+	]
+}`
+
+func TestBatchPublish(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "uuid-from-store").Return("hash-from-store", nil)
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "uuid-with-body", "hash", mock.Anything).Return("hash-with-body", nil)
+	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "uuid-not-found").Return("", annotations.ErrDraftNotFound)
+	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "uuid-failing").Return("", errors.New("eek"))
+	current := annotations.AnnotationsBody{Annotations: []annotations.Annotation{{Predicate: "foo", ConceptID: "bar"}}}
+	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "uuid-conflict").Return("", &annotations.ConflictError{Current: &current, CurrentHash: "current-hash"})
+
+	r.Post("/drafts/content/annotations/publish", BatchPublish(pub, timeout, 2, 10, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/annotations/publish", strings.NewReader(testBatchPublishBody))
+
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Results map[string]BatchPublishResult `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+
+	assert.Equal(t, BatchPublishResult{Status: http.StatusAccepted, Message: "Publish accepted", DocumentHash: "hash-from-store"}, resp.Results["uuid-from-store"])
+	assert.Equal(t, BatchPublishResult{Status: http.StatusAccepted, Message: "Publish accepted", DocumentHash: "hash-with-body"}, resp.Results["uuid-with-body"])
+	assert.Equal(t, BatchPublishResult{Status: http.StatusNotFound, Error: "not-found", Message: annotations.ErrDraftNotFound.Error()}, resp.Results["uuid-not-found"])
+	assert.Equal(t, BatchPublishResult{Status: http.StatusServiceUnavailable, Error: "publish-failed", Message: "eek"}, resp.Results["uuid-failing"])
+	assert.Equal(t, BatchPublishResult{Status: http.StatusConflict, Error: "conflict", Message: annotations.ErrConflict.Error(), DocumentHash: "current-hash", Annotations: current.Annotations}, resp.Results["uuid-conflict"])
+
+	pub.AssertExpectations(t)
+}
+
+func TestBatchPublishInvalidRequests(t *testing.T) {
+	tests := map[string]struct {
+		body    string
+		message string
+	}{
+		"not json": {
+			body:    `{\`,
+			message: "Failed to process request json. Please provide a valid json request body",
+		},
+		"no items": {
+			body:    `{"items":[]}`,
+			message: "Please provide at least one item to publish",
+		},
+		"too many items": {
+			body:    `{"items":[{"uuid":"1","fromStore":true},{"uuid":"2","fromStore":true},{"uuid":"3","fromStore":true}]}`,
+			message: "A batch cannot contain more than 2 items",
+		},
+		"missing uuid": {
+			body:    `{"items":[{"fromStore":true}]}`,
+			message: "Please specify a valid uuid for item 0",
+		},
+		"duplicate uuid": {
+			body:    `{"items":[{"uuid":"1","fromStore":true},{"uuid":"1","fromStore":true}]}`,
+			message: "UUID 1 is present more than once in the batch",
+		},
+		"fromStore with body": {
+			body:    `{"items":[{"uuid":"1","fromStore":true,"body":{"annotations":[{"predicate":"foo","id":"bar"}]}}]}`,
+			message: "A body cannot be provided when fromStore=true for 1",
+		},
+		"missing body": {
+			body:    `{"items":[{"uuid":"1"}]}`,
+			message: "Please provide a valid body for 1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := vestigo.NewRouter()
+			pub := &mockPublisher{}
+
+			r.Post("/drafts/content/annotations/publish", BatchPublish(pub, timeout, 2, 2, logger.NewUPPLogger("test", "DEBUG")))
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/drafts/content/annotations/publish", strings.NewReader(test.body))
+
+			r.ServeHTTP(w, req)
+
+			resp, err := marshal(w.Body)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, test.message, resp["message"])
+
+			pub.AssertExpectations(t)
+		})
+	}
+}
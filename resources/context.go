@@ -0,0 +1,32 @@
+package resources
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/auth"
+	"github.com/Financial-Times/annotations-publisher/outbound"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestContext returns a context holding the transaction ID, the span, the editor and the headers sent on to downstream services of the request,
+// including the ID of its authenticated client, but not its cancellation, since publishes carry on when the caller goes away
+func requestContext(r *http.Request, txid string) context.Context {
+	ctx := trace.ContextWithSpan(context.Background(), trace.SpanFromContext(r.Context()))
+	ctx = outbound.WithHeaders(ctx, outbound.Headers(r.Context()))
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+		ctx = outbound.WithClientID(ctx, identity.Client)
+	}
+	ctx = annotations.WithEditor(ctx, editor(r))
+	return tid.TransactionAwareContext(ctx, txid)
+}
+
+// editor returns the user of the JWT of the request, or the user named by its X-User-Id header if it was not made with a JWT
+func editor(r *http.Request) string {
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok && identity.User != "" {
+		return identity.User
+	}
+	return r.Header.Get(outbound.UserIDHeader)
+}
@@ -0,0 +1,42 @@
+package resources
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/husobee/vestigo"
+)
+
+// UnpublishedChanges provides the annotations which have been added, removed or changed in the draft annotations since they were last published
+func UnpublishedChanges(publisher annotations.Publisher, httpTimeOut time.Duration, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txid := tid.GetTransactionIDFromRequest(r)
+		mlog := log.WithField(tid.TransactionIDHeader, txid)
+		ctx, cancel := context.WithTimeout(requestContext(r, txid), httpTimeOut)
+		defer cancel()
+
+		uuid := vestigo.Param(r, "uuid")
+		if uuid == "" {
+			writeMsg(w, http.StatusBadRequest, "Please specify a valid uuid in the request")
+			return
+		}
+
+		diff, hash, err := publisher.UnpublishedChanges(ctx, uuid)
+		if status, _, ok := knownPublishError(err); ok {
+			writePublishError(w, status, err.Error(), err)
+			return
+		}
+		if err != nil {
+			mlog.WithError(err).Error("Unable to compare draft and published annotations")
+			writePublishError(w, http.StatusServiceUnavailable, "Unable to compare draft and published annotations", err)
+			return
+		}
+
+		w.Header().Set(annotations.DocumentHashHeader, hash)
+		writeJSON(w, http.StatusOK, diff)
+	}
+}
@@ -0,0 +1,68 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnpublishedChanges(t *testing.T) {
+	diff := annotations.AnnotationsDiff{
+		Added:   []annotations.Annotation{{Predicate: "http://www.ft.com/ontology/annotation/about", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd", Type: "TOPIC"}},
+		Removed: []annotations.Annotation{},
+		Changed: []annotations.AnnotationChange{},
+	}
+	pub := &mockPublisher{}
+	pub.On("UnpublishedChanges", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return(diff, "draft-hash", nil)
+
+	r := vestigo.NewRouter()
+	r.Get("/drafts/content/:uuid/annotations/diff", UnpublishedChanges(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/drafts/content/a-valid-uuid/annotations/diff", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "draft-hash", w.Header().Get(annotations.DocumentHashHeader))
+
+	var actual annotations.AnnotationsDiff
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&actual))
+	assert.Equal(t, diff, actual)
+
+	pub.AssertExpectations(t)
+}
+
+func TestUnpublishedChangesFails(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		status int
+	}{
+		"draft not found": {err: annotations.ErrDraftNotFound, status: http.StatusNotFound},
+		"timeout":         {err: annotations.ErrServiceTimeout, status: http.StatusGatewayTimeout},
+		"unknown":         {err: errors.New("eek"), status: http.StatusServiceUnavailable},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			pub := &mockPublisher{}
+			pub.On("UnpublishedChanges", mock.Anything, "a-valid-uuid").Return(annotations.AnnotationsDiff{}, "", test.err)
+
+			r := vestigo.NewRouter()
+			r.Get("/drafts/content/:uuid/annotations/diff", UnpublishedChanges(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/drafts/content/a-valid-uuid/annotations/diff", nil))
+
+			assert.Equal(t, test.status, w.Code)
+			pub.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package resources
+
+import (
+	"net/http"
+
+	"github.com/Financial-Times/annotations-publisher/audit"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+)
+
+// PublishHistory lists every recorded publish attempt of a piece of content, most recent first
+func PublishHistory(store audit.Store, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := vestigo.Param(r, "uuid")
+		if uuid == "" {
+			writeMsg(w, http.StatusBadRequest, "Please specify a valid uuid in the request")
+			return
+		}
+
+		records, err := store.List(uuid)
+		if err != nil {
+			log.WithError(err).WithUUID(uuid).Error("failed to read publish history")
+			writeMsg(w, http.StatusInternalServerError, "Failed to read the publish history")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"history": records})
+	}
+}
@@ -0,0 +1,48 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/annotations-publisher/audit"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishHistory(t *testing.T) {
+	store := audit.NewMemoryStore(10, 0)
+	require.NoError(t, store.Add(audit.Record{ID: "first", UUID: "a-valid-uuid", Outcome: audit.OutcomeSucceeded}))
+	require.NoError(t, store.Add(audit.Record{ID: "second", UUID: "a-valid-uuid", Outcome: audit.OutcomeFailed, Error: "eek"}))
+
+	r := vestigo.NewRouter()
+	r.Get("/content/:uuid/annotations/publish-history", PublishHistory(store, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/content/a-valid-uuid/annotations/publish-history", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		History []audit.Record `json:"history"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.History, 2)
+	assert.Equal(t, "second", resp.History[0].ID)
+	assert.Equal(t, "eek", resp.History[0].Error)
+	assert.Equal(t, "first", resp.History[1].ID)
+}
+
+func TestPublishHistoryOfUnpublishedContent(t *testing.T) {
+	r := vestigo.NewRouter()
+	r.Get("/content/:uuid/annotations/publish-history", PublishHistory(audit.NewMemoryStore(10, 0), logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/content/a-valid-uuid/annotations/publish-history", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"history": []}`, w.Body.String())
+}
@@ -0,0 +1,108 @@
+package resources
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/auth"
+	"github.com/Financial-Times/annotations-publisher/idempotency"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+)
+
+const (
+	// IdempotencyKeyHeader identifies repeats of the same request, so they are not handled more than once
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayHeader is set on responses which were stored for an earlier request with the same Idempotency-Key
+	IdempotentReplayHeader = "Idempotent-Replayed"
+)
+
+// idempotent handles the first request with an Idempotency-Key header with next, and responds to repeats of it within the window of the cache
+// with the stored response. Requests without the header are always handled. Failures with a 5xx status are not stored, so a repeat is handled again.
+func idempotent(cache *idempotency.Cache, log *logger.UPPLogger, next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			next(w, r)
+			return
+		}
+
+		txid := tid.GetTransactionIDFromRequest(r)
+		mlog := log.WithField(tid.TransactionIDHeader, txid).WithField("idempotencyKey", idempotencyKey)
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			mlog.WithField("reason", err).Warn("error reading body")
+			writeMsg(w, http.StatusBadRequest, "Failed to read request body. Please provide a valid json request body")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		// keys are only unique to a single caller's requests for a piece of content. Callers can only be told apart when they are authenticated.
+		var client string
+		if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+			client = identity.Client
+		}
+		key := r.Method + " " + r.URL.Path + " " + client + " " + idempotencyKey
+		stored, err := cache.Begin(key, requestFingerprint(r, body))
+		switch {
+		case errors.Is(err, idempotency.ErrMismatch):
+			writeMsg(w, http.StatusUnprocessableEntity, "The Idempotency-Key has already been used for a different request")
+			return
+		case errors.Is(err, idempotency.ErrInProgress):
+			writeMsg(w, http.StatusConflict, "A request with the same Idempotency-Key is in progress")
+			return
+		case stored != nil:
+			mlog.Info("replaying stored response to repeated request")
+			for name, values := range stored.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(IdempotentReplayHeader, "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		next(rw, r)
+
+		if rw.status >= http.StatusInternalServerError {
+			cache.Abandon(key)
+			return
+		}
+		cache.Complete(key, idempotency.Response{Status: rw.status, Header: w.Header().Clone(), Body: rw.body.Bytes()})
+	}
+}
+
+// requestFingerprint identifies what a request asks for, so a repeated Idempotency-Key can be checked against the request which first used it
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.URL.RawQuery))
+	h.Write([]byte{0})
+	h.Write([]byte(r.Header.Get(annotations.PreviousDocumentHashHeader)))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the status and body written to the response. The status is 200 unless another is written.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,68 @@
+package resources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/idempotency"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPublishIdempotencyKey(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.AnythingOfType("annotations.AnnotationsBody")).Return("new-hash", nil).Once()
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG"), WithIdempotency(idempotency.NewCache(time.Minute))))
+
+	publish := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish", strings.NewReader(body))
+		req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+		req.Header.Add(IdempotencyKeyHeader, "key")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := publish(testPublishBody)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "new-hash", w.Header().Get(annotations.DocumentHashHeader))
+	assert.Empty(t, w.Header().Get(IdempotentReplayHeader))
+
+	replay := publish(testPublishBody)
+	assert.Equal(t, http.StatusAccepted, replay.Code)
+	assert.Equal(t, "new-hash", replay.Header().Get(annotations.DocumentHashHeader))
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, w.Body.String(), replay.Body.String())
+
+	w = publish(`{"annotations":[{"predicate":"http://www.ft.com/ontology/annotation/about","id":"http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd"}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	pub.AssertExpectations(t)
+}
+
+func TestPublishIdempotencyKeyAfterFailure(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.AnythingOfType("annotations.AnnotationsBody")).Return("", annotations.ErrServiceTimeout).Once()
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.AnythingOfType("annotations.AnnotationsBody")).Return("new-hash", nil).Once()
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG"), WithIdempotency(idempotency.NewCache(time.Minute))))
+
+	for _, expected := range []int{http.StatusGatewayTimeout, http.StatusAccepted} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish", strings.NewReader(testPublishBody))
+		req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+		req.Header.Add(IdempotencyKeyHeader, "key")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, expected, w.Code)
+	}
+
+	pub.AssertExpectations(t)
+}
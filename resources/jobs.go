@@ -0,0 +1,39 @@
+package resources
+
+import (
+	"net/http"
+
+	"github.com/Financial-Times/annotations-publisher/jobs"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+)
+
+const publishJobsPath = "/publish-jobs/"
+
+// PublishJob provides the status of an asynchronous publish job
+func PublishJob(queue *jobs.Queue) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := queue.Get(vestigo.Param(r, "id"))
+		if !ok {
+			writeMsg(w, http.StatusNotFound, "Publish job not found")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func writeJob(w http.ResponseWriter, job jobs.Job, err error, mlog *logger.LogEntry) {
+	if err != nil {
+		mlog.WithError(err).Error("failed to queue publish job")
+		writeMsg(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", publishJobsPath+job.ID)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"message": "Publish queued",
+		"jobId":   job.ID,
+		"state":   job.State,
+	})
+}
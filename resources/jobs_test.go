@@ -0,0 +1,174 @@
+package resources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/auth"
+	"github.com/Financial-Times/annotations-publisher/jobs"
+	"github.com/Financial-Times/annotations-publisher/outbound"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncPublish(t *testing.T) {
+	log := logger.NewUPPLogger("test", "DEBUG")
+	pub := &mockPublisher{}
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("new-hash", nil)
+
+	queue := jobs.NewQueue(pub, 10, timeout, time.Hour, log)
+	queue.Start(1)
+
+	r := vestigo.NewRouter()
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, log, WithPublishJobs(queue)))
+	r.Get("/publish-jobs/:id", PublishJob(queue))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?async=true", strings.NewReader(testPublishBody))
+	req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+
+	r.ServeHTTP(w, req)
+
+	resp, err := marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "Publish queued", resp["message"])
+	jobID := resp["jobId"].(string)
+	assert.Equal(t, "/publish-jobs/"+jobID, w.Header().Get("Location"))
+
+	queue.Stop()
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/publish-jobs/"+jobID, nil)
+
+	r.ServeHTTP(w, req)
+
+	resp, err = marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, string(jobs.StateSucceeded), resp["state"])
+	assert.Equal(t, "new-hash", resp["documentHash"])
+	assert.Equal(t, "a-valid-uuid", resp["uuid"])
+
+	pub.AssertExpectations(t)
+}
+
+func TestAsyncPublishSendsOnCallerHeaders(t *testing.T) {
+	log := logger.NewUPPLogger("test", "DEBUG")
+	authenticator := testAuthenticator{"token": {Client: "a-client", User: "an-editor", Scopes: []auth.Scope{auth.ScopePublish, auth.ScopeFromStore}}}
+	pub := &mockPublisher{}
+	pub.On("PublishFromStore", mock.MatchedBy(func(ctx context.Context) bool {
+		headers := outbound.Headers(ctx)
+		return headers.Get("X-Request-Source") == "a-source" && headers.Get(outbound.ClientIDHeader) == "a-client"
+	}), "a-valid-uuid").Return("new-hash", nil)
+
+	queue := jobs.NewQueue(pub, 10, timeout, time.Hour, log)
+	queue.Start(1)
+
+	r := vestigo.NewRouter()
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, log, WithPublishJobs(queue)), Authorize(authenticator, PublishScopes, log))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?async=true&fromStore=true", nil)
+	req.Header.Set(auth.APIKeyHeader, "token")
+	req.Header.Set("X-Request-Source", "a-source")
+
+	outbound.NewPropagator("", []string{"X-Request-Source"}).Handler(r).ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	// the job runs after the request has finished
+	queue.Stop()
+	pub.AssertExpectations(t)
+}
+
+func TestAsyncPublishFromStore(t *testing.T) {
+	log := logger.NewUPPLogger("test", "DEBUG")
+	pub := &mockPublisher{}
+
+	queue := jobs.NewQueue(pub, 10, timeout, time.Hour, log)
+
+	r := vestigo.NewRouter()
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, log, WithPublishJobs(queue)))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?async=true&fromStore=true", nil)
+
+	r.ServeHTTP(w, req)
+
+	resp, err := marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	job, ok := queue.Get(resp["jobId"].(string))
+	require.True(t, ok)
+	assert.Equal(t, jobs.StateQueued, job.State)
+	assert.True(t, job.FromStore)
+
+	pub.AssertExpectations(t)
+}
+
+func TestAsyncPublishQueueFull(t *testing.T) {
+	log := logger.NewUPPLogger("test", "DEBUG")
+	pub := &mockPublisher{}
+
+	queue := jobs.NewQueue(pub, 0, timeout, time.Hour, log)
+
+	r := vestigo.NewRouter()
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, log, WithPublishJobs(queue)))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?async=true&fromStore=true", nil)
+
+	r.ServeHTTP(w, req)
+
+	resp, err := marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "Publish job queue is full", resp["message"])
+
+	pub.AssertExpectations(t)
+}
+
+func TestAsyncPublishNotEnabled(t *testing.T) {
+	pub := &mockPublisher{}
+
+	r := vestigo.NewRouter()
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?async=true&fromStore=true", nil)
+
+	r.ServeHTTP(w, req)
+
+	resp, err := marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Asynchronous publishing is not enabled", resp["message"])
+
+	pub.AssertExpectations(t)
+}
+
+func TestPublishJobNotFound(t *testing.T) {
+	queue := jobs.NewQueue(&mockPublisher{}, 10, timeout, time.Hour, logger.NewUPPLogger("test", "DEBUG"))
+
+	r := vestigo.NewRouter()
+	r.Get("/publish-jobs/:id", PublishJob(queue))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/publish-jobs/unknown", nil)
+
+	r.ServeHTTP(w, req)
+
+	resp, err := marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "Publish job not found", resp["message"])
+}
@@ -0,0 +1,15 @@
+package resources
+
+import (
+	"net/http"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Metrics writes the current value of every metric in the registry as JSON, including the usage of the bulkheads of the downstream services
+func Metrics(registry metrics.Registry) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		metrics.WriteJSONOnce(registry, w)
+	}
+}
@@ -3,24 +3,58 @@ package resources
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/breaker"
+	"github.com/Financial-Times/annotations-publisher/bulkhead"
+	"github.com/Financial-Times/annotations-publisher/idempotency"
+	"github.com/Financial-Times/annotations-publisher/jobs"
 	"github.com/Financial-Times/go-logger/v2"
 	tid "github.com/Financial-Times/transactionid-utils-go"
 	"github.com/husobee/vestigo"
 )
 
+// PublishOption configures optional behaviour of the Publish handler
+type PublishOption func(o *publishOptions)
+
+type publishOptions struct {
+	jobs        *jobs.Queue
+	idempotency *idempotency.Cache
+}
+
+// WithPublishJobs enables asynchronous publishes with the async=true query parameter, which are run by the provided queue
+func WithPublishJobs(queue *jobs.Queue) PublishOption {
+	return func(o *publishOptions) {
+		o.jobs = queue
+	}
+}
+
+// WithIdempotency replays the stored response to requests which repeat the Idempotency-Key header and body of an earlier request, instead of publishing again.
+// A repeated Idempotency-Key with a different request is rejected with a 422.
+func WithIdempotency(cache *idempotency.Cache) PublishOption {
+	return func(o *publishOptions) {
+		o.idempotency = cache
+	}
+}
+
 // Publish provides functionality to publish PAC annotations to UPP
-func Publish(publisher annotations.Publisher, httpTimeOut time.Duration, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
+func Publish(publisher annotations.Publisher, httpTimeOut time.Duration, log *logger.UPPLogger, options ...PublishOption) func(w http.ResponseWriter, r *http.Request) {
+	opts := &publishOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
 		txid := tid.GetTransactionIDFromRequest(r)
 		mlog := log.WithField(tid.TransactionIDHeader, txid)
-		ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), txid), httpTimeOut)
+		ctx, cancel := context.WithTimeout(requestContext(r, txid), httpTimeOut)
 		defer cancel()
 
 		uuid := vestigo.Param(r, "uuid")
@@ -30,8 +64,20 @@ func Publish(publisher annotations.Publisher, httpTimeOut time.Duration, log *lo
 		}
 
 		fromStore, _ := strconv.ParseBool(r.URL.Query().Get("fromStore"))
+		async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+		merge, _ := strconv.ParseBool(r.URL.Query().Get("merge"))
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
 		hash := r.Header.Get(annotations.PreviousDocumentHashHeader)
-		log.WithFields(map[string]interface{}{"transaction_id": txid, "uuid": uuid, "fromStore": fromStore}).Info("publish")
+		log.WithFields(map[string]interface{}{"transaction_id": txid, "uuid": uuid, "fromStore": fromStore, "async": async, "merge": merge, "dryRun": dryRun}).Info("publish")
+
+		if async && opts.jobs == nil {
+			writeMsg(w, http.StatusBadRequest, "Asynchronous publishing is not enabled")
+			return
+		}
+		if async && dryRun {
+			writeMsg(w, http.StatusBadRequest, "A dry run cannot be asynchronous")
+			return
+		}
 
 		var body annotations.AnnotationsBody
 
@@ -46,10 +92,23 @@ func Publish(publisher annotations.Publisher, httpTimeOut time.Duration, log *lo
 			writeMsg(w, http.StatusBadRequest, "A request body cannot be provided when fromStore=true")
 			return
 		}
+		if fromStore && merge {
+			writeMsg(w, http.StatusBadRequest, "Merging cannot be requested when fromStore=true")
+			return
+		}
 		if !fromStore && len(bodyBytes) == 0 {
 			writeMsg(w, http.StatusBadRequest, "Please provide a valid json request body")
 			return
 		}
+		if fromStore && async {
+			job, err := opts.jobs.SubmitFromStore(ctx, uuid)
+			writeJob(w, job, err, mlog)
+			return
+		}
+		if fromStore && dryRun {
+			previewPublish(ctx, publisher, uuid, "", nil, false, w, log)
+			return
+		}
 		if fromStore {
 			publishFromStore(ctx, publisher, uuid, w, log)
 			return
@@ -60,32 +119,50 @@ func Publish(publisher annotations.Publisher, httpTimeOut time.Duration, log *lo
 			writeMsg(w, http.StatusBadRequest, "Failed to process request json. Please provide a valid json request body")
 			return
 		}
-		saveAndPublish(ctx, publisher, uuid, hash, w, body, log)
+		if dryRun {
+			previewPublish(ctx, publisher, uuid, hash, &body, merge, w, log)
+			return
+		}
+		if async && merge {
+			job, err := opts.jobs.SubmitMergeAndPublish(ctx, uuid, hash, body)
+			writeJob(w, job, err, mlog)
+			return
+		}
+		if async {
+			job, err := opts.jobs.SubmitSaveAndPublish(ctx, uuid, hash, body)
+			writeJob(w, job, err, mlog)
+			return
+		}
+		saveAndPublish(ctx, publisher, uuid, hash, merge, w, body, log)
+	}
+
+	if opts.idempotency != nil {
+		handler = idempotent(opts.idempotency, log, handler)
 	}
+	return traced("Publish", handler)
 }
 
-func saveAndPublish(ctx context.Context, publisher annotations.Publisher, uuid string, hash string, w http.ResponseWriter, body annotations.AnnotationsBody, log *logger.UPPLogger) {
+func saveAndPublish(ctx context.Context, publisher annotations.Publisher, uuid string, hash string, merge bool, w http.ResponseWriter, body annotations.AnnotationsBody, log *logger.UPPLogger) {
 	txid, _ := tid.GetTransactionIDFromContext(ctx)
 	mlog := log.WithField(tid.TransactionIDHeader, txid)
 
-	err := publisher.SaveAndPublish(ctx, uuid, hash, body)
-	if err == annotations.ErrServiceTimeout {
-		writeMsg(w, http.StatusGatewayTimeout, err.Error())
-		return
-	}
-	if err == annotations.ErrInvalidAuthentication { // the service config needs to be updated for this to work
-		writeMsg(w, http.StatusInternalServerError, err.Error())
-		return
+	var newHash string
+	var err error
+	if merge {
+		newHash, err = publisher.MergeAndPublish(ctx, uuid, hash, body)
+	} else {
+		newHash, err = publisher.SaveAndPublish(ctx, uuid, hash, body)
 	}
-	if err == annotations.ErrDraftNotFound {
-		writeMsg(w, http.StatusNotFound, err.Error())
+	if status, _, ok := knownPublishError(err); ok {
+		writePublishError(w, status, err.Error(), err)
 		return
 	}
 	if err != nil {
 		mlog.WithField("reason", err).Error("failed to publish annotations to UPP")
-		writeMsg(w, http.StatusServiceUnavailable, err.Error())
+		writePublishError(w, http.StatusServiceUnavailable, err.Error(), err)
 		return
 	}
+	w.Header().Set(annotations.DocumentHashHeader, newHash)
 	writeMsg(w, http.StatusAccepted, "Publish accepted")
 }
 
@@ -93,26 +170,144 @@ func publishFromStore(ctx context.Context, publisher annotations.Publisher, uuid
 	txid, _ := tid.GetTransactionIDFromContext(ctx)
 	mlog := log.WithField(tid.TransactionIDHeader, txid)
 
-	err := publisher.PublishFromStore(ctx, uuid)
+	newHash, err := publisher.PublishFromStore(ctx, uuid)
 	if err == nil {
+		w.Header().Set(annotations.DocumentHashHeader, newHash)
 		writeMsg(w, http.StatusAccepted, "Publish accepted")
-	} else if err == annotations.ErrServiceTimeout {
-		writeMsg(w, http.StatusGatewayTimeout, err.Error())
-	} else if err == annotations.ErrDraftNotFound {
-		writeMsg(w, http.StatusNotFound, err.Error())
+	} else if status, _, ok := knownPublishError(err); ok {
+		writePublishError(w, status, err.Error(), err)
 	} else {
 		mlog.WithError(err).Error("Unable to publish annotations from store")
-		writeMsg(w, http.StatusInternalServerError, "Unable to publish annotations from store")
+		writePublishError(w, http.StatusInternalServerError, "Unable to publish annotations from store", err)
 	}
 }
 
+func previewPublish(ctx context.Context, publisher annotations.Publisher, uuid string, hash string, body *annotations.AnnotationsBody, merge bool, w http.ResponseWriter, log *logger.UPPLogger) {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	mlog := log.WithField(tid.TransactionIDHeader, txid)
+
+	payload, err := publisher.PreviewPublish(ctx, uuid, hash, body, merge)
+	if status, _, ok := knownPublishError(err); ok {
+		writePublishError(w, status, err.Error(), err)
+		return
+	}
+	if err != nil {
+		mlog.WithError(err).Error("Unable to preview publish")
+		writePublishError(w, http.StatusServiceUnavailable, "Unable to preview publish", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Dry run succeeded, nothing was saved or published",
+		"payload": payload,
+	})
+}
+
+// knownPublishError maps the errors returned by the annotations.Publisher to the http status and error category reported to callers
+func knownPublishError(err error) (int, string, bool) {
+	switch {
+	case errors.Is(err, annotations.ErrServiceTimeout):
+		return http.StatusGatewayTimeout, "timeout", true
+	case errors.Is(err, annotations.ErrDraftNotFound), errors.Is(err, annotations.ErrNotPublished):
+		return http.StatusNotFound, "not-found", true
+	case errors.Is(err, annotations.ErrInvalidAuthentication): // the service config needs to be updated for this to work
+		return http.StatusInternalServerError, "authentication", true
+	case errors.Is(err, annotations.ErrConflict):
+		return http.StatusConflict, "conflict", true
+	case errors.Is(err, annotations.ErrInvalidAnnotations):
+		return http.StatusBadRequest, "invalid-annotations", true
+	case errors.Is(err, breaker.ErrOpen):
+		return http.StatusServiceUnavailable, "circuit-open", true
+	case errors.Is(err, bulkhead.ErrFull):
+		return http.StatusServiceUnavailable, "bulkhead-full", true
+	}
+	return 0, "", false
+}
+
+// rollbackOutcome returns the outcome of the rollback of a failed transactional publish, if there was one
+func rollbackOutcome(err error) annotations.RollbackOutcome {
+	var rollbackErr *annotations.RollbackError
+	if errors.As(err, &rollbackErr) {
+		return rollbackErr.Outcome
+	}
+	return ""
+}
+
+// retryAfter returns the whole number of seconds until the open circuit breaker which rejected the publish lets requests through again
+func retryAfter(err error) (int, bool) {
+	var openErr *breaker.OpenError
+	if !errors.As(err, &openErr) {
+		return 0, false
+	}
+	return int(math.Ceil(openErr.RetryAfter.Seconds())), true
+}
+
+// currentDraft returns the current draft annotations reported by a conflicting publish, if they could be read
+func currentDraft(err error) (*annotations.AnnotationsBody, string) {
+	var conflictErr *annotations.ConflictError
+	if errors.As(err, &conflictErr) {
+		return conflictErr.Current, conflictErr.CurrentHash
+	}
+	return nil, ""
+}
+
+// mergeConflicts returns the annotations which prevented a merge of a conflicting publish, if one was attempted
+func mergeConflicts(err error) []annotations.MergeConflict {
+	var conflictErr *annotations.ConflictError
+	if errors.As(err, &conflictErr) {
+		return conflictErr.Conflicts
+	}
+	return nil
+}
+
+// fieldErrors returns the invalid fields of annotations rejected by validation
+func fieldErrors(err error) []annotations.FieldError {
+	var validationErr *annotations.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Errors
+	}
+	return nil
+}
+
+// writePublishError writes msg with the given status, and reports the rollback of the published annotations caused by err, if any,
+// the current draft annotations and the annotations which could not be merged if err is a conflict, or the invalid fields if the annotations were rejected
+func writePublishError(w http.ResponseWriter, status int, msg string, err error) {
+	if seconds, ok := retryAfter(err); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+
+	resp := map[string]interface{}{"message": capitalise(msg)}
+	if outcome := rollbackOutcome(err); outcome != "" {
+		resp["rollback"] = outcome
+	}
+	if current, hash := currentDraft(err); current != nil {
+		w.Header().Set(annotations.DocumentHashHeader, hash)
+		resp["annotations"] = current.Annotations
+	}
+	if conflicts := mergeConflicts(err); len(conflicts) > 0 {
+		resp["conflicts"] = conflicts
+	}
+	if fieldErrs := fieldErrors(err); len(fieldErrs) > 0 {
+		resp["errors"] = fieldErrs
+	}
+	writeJSON(w, status, resp)
+}
+
 func writeMsg(w http.ResponseWriter, status int, msg string) {
+	resp := make(map[string]interface{})
+	resp["message"] = capitalise(msg)
+
+	writeJSON(w, status, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, resp interface{}) {
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(status)
 
-	resp := make(map[string]interface{})
-	resp["message"] = strings.ToUpper(msg[:1]) + msg[1:]
-
 	enc := json.NewEncoder(w)
 	enc.Encode(&resp)
 }
+
+func capitalise(msg string) string {
+	return strings.ToUpper(msg[:1]) + msg[1:]
+}
@@ -7,11 +7,15 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/breaker"
+	"github.com/Financial-Times/annotations-publisher/bulkhead"
+	"github.com/Financial-Times/annotations-publisher/outbound"
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/husobee/vestigo"
 	"github.com/stretchr/testify/assert"
@@ -42,7 +46,7 @@ var timeout = 8 * time.Second
 func TestPublish(t *testing.T) {
 	r := vestigo.NewRouter()
 	pub := &mockPublisher{}
-	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return(nil)
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("new-hash", nil)
 
 	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
 
@@ -53,6 +57,7 @@ func TestPublish(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "new-hash", w.Header().Get(annotations.DocumentHashHeader))
 
 	pub.AssertExpectations(t)
 }
@@ -79,7 +84,7 @@ func TestBodyNotJSON(t *testing.T) {
 func TestPublishNotFound(t *testing.T) {
 	r := vestigo.NewRouter()
 	pub := &mockPublisher{}
-	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return(annotations.ErrDraftNotFound)
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("", annotations.ErrDraftNotFound)
 
 	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
 
@@ -100,7 +105,7 @@ func TestPublishNotFound(t *testing.T) {
 func TestPublishTimedout(t *testing.T) {
 	r := vestigo.NewRouter()
 	pub := &mockPublisher{}
-	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return(annotations.ErrServiceTimeout)
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("", annotations.ErrServiceTimeout)
 
 	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
 
@@ -166,7 +171,7 @@ func TestPublishBodyReadFail(t *testing.T) {
 func TestPublishNoHashHeader(t *testing.T) {
 	r := vestigo.NewRouter()
 	pub := &mockPublisher{}
-	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "", mock.Anything).Return(nil)
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "", mock.Anything).Return("new-hash", nil)
 
 	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
 
@@ -202,7 +207,7 @@ func TestRequestHasNoUUID(t *testing.T) {
 func TestPublishFailed(t *testing.T) {
 	r := vestigo.NewRouter()
 	pub := &mockPublisher{}
-	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return(errors.New("eek"))
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("", errors.New("eek"))
 
 	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
 
@@ -223,7 +228,7 @@ func TestPublishFailed(t *testing.T) {
 func TestPublishAuthenticationInvalid(t *testing.T) {
 	r := vestigo.NewRouter()
 	pub := &mockPublisher{}
-	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return(annotations.ErrInvalidAuthentication)
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("", annotations.ErrInvalidAuthentication)
 
 	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
 
@@ -244,7 +249,7 @@ func TestPublishAuthenticationInvalid(t *testing.T) {
 func TestPublishFromStore(t *testing.T) {
 	r := vestigo.NewRouter()
 	pub := &mockPublisher{}
-	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return(nil)
+	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return("new-hash", nil)
 	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
 
 	w := httptest.NewRecorder()
@@ -263,7 +268,7 @@ func TestPublishFromStore(t *testing.T) {
 func TestPublishFromStoreNotFound(t *testing.T) {
 	r := vestigo.NewRouter()
 	pub := &mockPublisher{}
-	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return(annotations.ErrDraftNotFound)
+	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return("", annotations.ErrDraftNotFound)
 	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
 
 	w := httptest.NewRecorder()
@@ -282,7 +287,7 @@ func TestPublishFromStoreNotFound(t *testing.T) {
 func TestPublishFromStoreTimeout(t *testing.T) {
 	r := vestigo.NewRouter()
 	pub := &mockPublisher{}
-	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return(annotations.ErrServiceTimeout)
+	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return("", annotations.ErrServiceTimeout)
 	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
 
 	w := httptest.NewRecorder()
@@ -321,7 +326,7 @@ func TestPublishFromStoreTrueWithBody(t *testing.T) {
 func TestPublishFromStoreFails(t *testing.T) {
 	r := vestigo.NewRouter()
 	pub := &mockPublisher{}
-	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return(errors.New("test error"))
+	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return("", errors.New("test error"))
 	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
 
 	w := httptest.NewRecorder()
@@ -337,6 +342,200 @@ func TestPublishFromStoreFails(t *testing.T) {
 	pub.AssertExpectations(t)
 }
 
+func TestPublishReportsRollback(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	pub.On("PublishFromStore", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return("", &annotations.RollbackError{Cause: annotations.ErrServiceTimeout, Outcome: annotations.RollbackSucceeded})
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?fromStore=true", nil)
+
+	r.ServeHTTP(w, req)
+
+	resp, err := marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, annotations.ErrServiceTimeout.Error(), strings.ToLower(resp["message"].(string)))
+	assert.Equal(t, "succeeded", resp["rollback"])
+
+	pub.AssertExpectations(t)
+}
+
+func TestPublishCircuitBreakerOpen(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	openErr := &url.Error{Op: "Post", URL: "http://upp/notify", Err: &breaker.OpenError{Name: "upp-publish", RetryAfter: 1500 * time.Millisecond}}
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("", openErr)
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish", strings.NewReader(testPublishBody))
+	req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	pub.AssertExpectations(t)
+}
+
+func TestPublishBulkheadFull(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	fullErr := &url.Error{Op: "Put", URL: "http://published-annotations-rw", Err: &bulkhead.FullError{Name: "published-annotations-rw"}}
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("", fullErr)
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish", strings.NewReader(testPublishBody))
+	req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	pub.AssertExpectations(t)
+}
+
+func TestPublishConflict(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	current := annotations.AnnotationsBody{Annotations: []annotations.Annotation{{Predicate: "http://www.ft.com/ontology/annotation/about", ConceptID: "http://www.ft.com/thing/d7de27f8-1633-3fcc-b308-c95a2ad7d1cd"}}}
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("", &annotations.ConflictError{Current: &current, CurrentHash: "current-hash"})
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish", strings.NewReader(testPublishBody))
+	req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "current-hash", w.Header().Get(annotations.DocumentHashHeader))
+
+	var resp struct {
+		Message     string                   `json:"message"`
+		Annotations []annotations.Annotation `json:"annotations"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "Annotations have been changed since they were read", resp.Message)
+	assert.Equal(t, current.Annotations, resp.Annotations)
+
+	pub.AssertExpectations(t)
+}
+
+func TestPublishInvalidAnnotations(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	fieldErrs := []annotations.FieldError{{Field: "annotations[0].type", Value: "SPACESHIP", Message: "is not a known concept type"}}
+	pub.On("SaveAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("", &annotations.ValidationError{Errors: fieldErrs})
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish", strings.NewReader(testPublishBody))
+	req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp struct {
+		Message string                   `json:"message"`
+		Errors  []annotations.FieldError `json:"errors"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "Annotations are invalid: annotations[0].type is not a known concept type", resp.Message)
+	assert.Equal(t, fieldErrs, resp.Errors)
+
+	pub.AssertExpectations(t)
+}
+
+func TestPublishMergeConflict(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	current := annotations.AnnotationsBody{Annotations: []annotations.Annotation{{Predicate: "http://www.ft.com/ontology/annotation/mentions", ConceptID: "http://www.ft.com/thing/0a619d71-9af5-3755-90dd-f789b686c67a", Type: "ORGANISATION"}}}
+	conflicts := []annotations.MergeConflict{{Predicate: current.Annotations[0].Predicate, ConceptID: current.Annotations[0].ConceptID, Current: &current.Annotations[0]}}
+	pub.On("MergeAndPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.Anything).Return("", &annotations.ConflictError{Current: &current, CurrentHash: "current-hash", Conflicts: conflicts})
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?merge=true", strings.NewReader(testPublishBody))
+	req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "current-hash", w.Header().Get(annotations.DocumentHashHeader))
+
+	var resp struct {
+		Annotations []annotations.Annotation    `json:"annotations"`
+		Conflicts   []annotations.MergeConflict `json:"conflicts"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, current.Annotations, resp.Annotations)
+	assert.Equal(t, conflicts, resp.Conflicts)
+
+	pub.AssertExpectations(t)
+}
+
+func TestPublishMergeFromStore(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?fromStore=true&merge=true", nil)
+
+	r.ServeHTTP(w, req)
+
+	resp, err := marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Merging cannot be requested when fromStore=true", resp["message"])
+
+	pub.AssertExpectations(t)
+}
+
+func TestPublishDryRun(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	payload := map[string]interface{}{"annotations": []interface{}{}, "uuid": "a-valid-uuid"}
+	pub.On("PreviewPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "hash", mock.AnythingOfType("*annotations.AnnotationsBody"), false).Return(payload, nil)
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?dryRun=true", strings.NewReader(testPublishBody))
+	req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+
+	r.ServeHTTP(w, req)
+
+	resp, err := marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, payload, resp["payload"])
+
+	pub.AssertExpectations(t)
+}
+
+func TestPublishFromStoreDryRun(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	pub.On("PreviewPublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid", "", (*annotations.AnnotationsBody)(nil), false).Return(nil, annotations.ErrDraftNotFound)
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?fromStore=true&dryRun=true", nil)
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	pub.AssertExpectations(t)
+}
+
 func marshal(body *bytes.Buffer) (map[string]interface{}, error) {
 	j := make(map[string]interface{})
 	dec := json.NewDecoder(body)
@@ -361,16 +560,42 @@ func (m *mockPublisher) Publish(ctx context.Context, uuid string, body map[strin
 	return args.Error(0)
 }
 
-func (m *mockPublisher) PublishFromStore(ctx context.Context, uuid string) error {
+func (m *mockPublisher) PublishFromStore(ctx context.Context, uuid string) (string, error) {
 	args := m.Called(ctx, uuid)
-	return args.Error(0)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockPublisher) SaveAndPublish(ctx context.Context, uuid string, hash string, body annotations.AnnotationsBody) (string, error) {
+	args := m.Called(ctx, uuid, hash, body)
+	return args.String(0), args.Error(1)
 }
 
-func (m *mockPublisher) SaveAndPublish(ctx context.Context, uuid string, hash string, body annotations.AnnotationsBody) error {
+func (m *mockPublisher) MergeAndPublish(ctx context.Context, uuid string, hash string, body annotations.AnnotationsBody) (string, error) {
 	args := m.Called(ctx, uuid, hash, body)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockPublisher) UnpublishedChanges(ctx context.Context, uuid string) (annotations.AnnotationsDiff, string, error) {
+	args := m.Called(ctx, uuid)
+	return args.Get(0).(annotations.AnnotationsDiff), args.String(1), args.Error(2)
+}
+
+func (m *mockPublisher) Republish(ctx context.Context, uuid string) (string, error) {
+	args := m.Called(ctx, uuid)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockPublisher) Unpublish(ctx context.Context, uuid string) error {
+	args := m.Called(ctx, uuid)
 	return args.Error(0)
 }
 
+func (m *mockPublisher) PreviewPublish(ctx context.Context, uuid string, hash string, body *annotations.AnnotationsBody, merge bool) (map[string]interface{}, error) {
+	args := m.Called(ctx, uuid, hash, body, merge)
+	payload, _ := args.Get(0).(map[string]interface{})
+	return payload, args.Error(1)
+}
+
 func (m *mockPublisher) GetDraft(ctx context.Context, uuid string) (interface{}, error) {
 	args := m.Called(ctx, uuid)
 	return args.Get(0), args.Error(1)
@@ -380,3 +605,23 @@ func (m *mockPublisher) SaveDraft(ctx context.Context, uuid string, _ interface{
 	args := m.Called(ctx, uuid)
 	return args.Get(0), args.Error(1)
 }
+
+func TestPublishSendsOnCallerHeaders(t *testing.T) {
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	pub.On("SaveAndPublish", mock.MatchedBy(func(ctx context.Context) bool {
+		return outbound.Headers(ctx).Get(outbound.UserIDHeader) == "an-editor"
+	}), "a-valid-uuid", "hash", mock.Anything).Return("new-hash", nil)
+
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish", strings.NewReader(testPublishBody))
+	req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+	req.Header.Add(outbound.UserIDHeader, "an-editor")
+
+	outbound.NewPropagator("", nil).Handler(r).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	pub.AssertExpectations(t)
+}
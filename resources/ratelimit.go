@@ -0,0 +1,85 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/Financial-Times/annotations-publisher/ratelimit"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/husobee/vestigo"
+)
+
+// APIKeyHeader identifies the client of a request for its rate limit. Clients without an API key are identified by their User-Agent.
+const APIKeyHeader = "X-Api-Key"
+
+var rateLimitMessages = map[ratelimit.Scope]string{
+	ratelimit.ScopeGlobal:  "Too many publish requests, please retry later",
+	ratelimit.ScopeClient:  "Too many publish requests from this client, please retry later",
+	ratelimit.ScopeContent: "Too many publish requests for this content, please retry later",
+}
+
+// RateLimit returns a middleware which responds with a 429 and a Retry-After header to requests which exceed the limits of the limiter.
+// Requests for a piece of content are also counted against the limit of its uuid.
+func RateLimit(limiter *ratelimit.Limiter, log *logger.UPPLogger) vestigo.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			uuid := vestigo.Param(r, "uuid")
+			if !rateLimited(w, r, limiter.Allow(rateLimitClient(r), uuid), log) {
+				next(w, r)
+			}
+		}
+	}
+}
+
+// BatchRateLimit returns a middleware which rate limits batch publishes like RateLimit, counting every item of the batch as a request for its uuid
+func BatchRateLimit(limiter *ratelimit.Limiter, log *logger.UPPLogger) vestigo.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				log.WithTransactionID(tid.GetTransactionIDFromRequest(r)).WithError(err).Warn("error reading body")
+				writeMsg(w, http.StatusBadRequest, "Failed to read request body. Please provide a valid json request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// an invalid batch is rejected by the handler, and counts as a single request
+			var batch BatchPublishRequest
+			_ = json.Unmarshal(body, &batch)
+			uuids := make([]string, 0, len(batch.Items))
+			for _, item := range batch.Items {
+				uuids = append(uuids, item.UUID)
+			}
+
+			if !rateLimited(w, r, limiter.AllowMany(rateLimitClient(r), uuids), log) {
+				next(w, r)
+			}
+		}
+	}
+}
+
+// rateLimited responds with a 429 if err reports that the request exceeds a limit, and reports whether it did
+func rateLimited(w http.ResponseWriter, r *http.Request, err error, log *logger.UPPLogger) bool {
+	var limitedErr *ratelimit.LimitedError
+	if !errors.As(err, &limitedErr) {
+		return false
+	}
+
+	log.WithTransactionID(tid.GetTransactionIDFromRequest(r)).WithUUID(vestigo.Param(r, "uuid")).WithField("limit", limitedErr.Scope).Warn("request rejected by rate limit")
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(limitedErr.RetryAfter.Seconds())))))
+	writeMsg(w, http.StatusTooManyRequests, rateLimitMessages[limitedErr.Scope])
+	return true
+}
+
+func rateLimitClient(r *http.Request) string {
+	if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+		return "key:" + apiKey
+	}
+	return "agent:" + r.Header.Get("User-Agent")
+}
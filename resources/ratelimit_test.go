@@ -0,0 +1,153 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/annotations-publisher/ratelimit"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{}, ratelimit.Limit{Rate: 1, Burst: 1}, ratelimit.Limit{Rate: 1, Burst: 1})
+	r := vestigo.NewRouter()
+	handled := 0
+	r.Post("/content/:uuid/annotations/republish", func(w http.ResponseWriter, r *http.Request) {
+		handled++
+		writeMsg(w, http.StatusAccepted, "Republish accepted")
+	}, RateLimit(limiter, logger.NewUPPLogger("test", "DEBUG")))
+
+	tests := []struct {
+		name           string
+		uuid           string
+		apiKey         string
+		userAgent      string
+		expectedStatus int
+		expectedMsg    string
+	}{
+		{
+			name:           "first request",
+			uuid:           "a-valid-uuid",
+			apiKey:         "key",
+			expectedStatus: http.StatusAccepted,
+			expectedMsg:    "Republish accepted",
+		},
+		{
+			name:           "same content",
+			uuid:           "a-valid-uuid",
+			userAgent:      "agent",
+			expectedStatus: http.StatusTooManyRequests,
+			expectedMsg:    "Too many publish requests for this content, please retry later",
+		},
+		{
+			name:           "same client",
+			uuid:           "another-uuid",
+			apiKey:         "key",
+			userAgent:      "agent",
+			expectedStatus: http.StatusTooManyRequests,
+			expectedMsg:    "Too many publish requests from this client, please retry later",
+		},
+		{
+			name:           "client identified by user agent",
+			uuid:           "another-uuid",
+			userAgent:      "agent",
+			expectedStatus: http.StatusAccepted,
+			expectedMsg:    "Republish accepted",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/content/"+test.uuid+"/annotations/republish", nil)
+			if test.apiKey != "" {
+				req.Header.Set(APIKeyHeader, test.apiKey)
+			}
+			req.Header.Set("User-Agent", test.userAgent)
+
+			r.ServeHTTP(w, req)
+
+			resp, err := marshal(w.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedStatus, w.Code)
+			assert.Equal(t, test.expectedMsg, resp["message"])
+			if test.expectedStatus == http.StatusTooManyRequests {
+				assert.Equal(t, "1", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+	assert.Equal(t, 2, handled)
+}
+
+func TestBatchRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{}, ratelimit.Limit{Rate: 1, Burst: 3}, ratelimit.Limit{Rate: 1, Burst: 1})
+	r := vestigo.NewRouter()
+	var handled []int
+	r.Post("/drafts/content/annotations/publish", func(w http.ResponseWriter, r *http.Request) {
+		var batch BatchPublishRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&batch), "the body should still be readable")
+		handled = append(handled, len(batch.Items))
+		writeMsg(w, http.StatusOK, "Batch processed")
+	}, BatchRateLimit(limiter, logger.NewUPPLogger("test", "DEBUG")))
+
+	tests := []struct {
+		name           string
+		uuids          []string
+		expectedStatus int
+		expectedMsg    string
+	}{
+		{
+			name:           "first batch",
+			uuids:          []string{"uuid-1", "uuid-2"},
+			expectedStatus: http.StatusOK,
+			expectedMsg:    "Batch processed",
+		},
+		{
+			name:           "content already published",
+			uuids:          []string{"uuid-3", "uuid-1"},
+			expectedStatus: http.StatusTooManyRequests,
+			expectedMsg:    "Too many publish requests for this content, please retry later",
+		},
+		{
+			name:           "every item counts against the client",
+			uuids:          []string{"uuid-3", "uuid-4"},
+			expectedStatus: http.StatusTooManyRequests,
+			expectedMsg:    "Too many publish requests from this client, please retry later",
+		},
+		{
+			name:           "remaining token of the client",
+			uuids:          []string{"uuid-3"},
+			expectedStatus: http.StatusOK,
+			expectedMsg:    "Batch processed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items := make([]BatchPublishItem, 0, len(test.uuids))
+			for _, uuid := range test.uuids {
+				items = append(items, BatchPublishItem{UUID: uuid, FromStore: true})
+			}
+			body, err := json.Marshal(BatchPublishRequest{Items: items})
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/drafts/content/annotations/publish", bytes.NewReader(body))
+			req.Header.Set(APIKeyHeader, "key")
+
+			r.ServeHTTP(w, req)
+
+			resp, err := marshal(w.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedStatus, w.Code)
+			assert.Equal(t, test.expectedMsg, resp["message"])
+		})
+	}
+	assert.Equal(t, []int{2, 1}, handled)
+}
@@ -0,0 +1,43 @@
+package resources
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/husobee/vestigo"
+)
+
+// Republish publishes the annotations which PAC has published for a piece of content to UPP again, without touching the draft annotations
+func Republish(publisher annotations.Publisher, httpTimeOut time.Duration, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txid := tid.GetTransactionIDFromRequest(r)
+		mlog := log.WithField(tid.TransactionIDHeader, txid)
+		ctx, cancel := context.WithTimeout(requestContext(r, txid), httpTimeOut)
+		defer cancel()
+
+		uuid := vestigo.Param(r, "uuid")
+		if uuid == "" {
+			writeMsg(w, http.StatusBadRequest, "Please specify a valid uuid in the request")
+			return
+		}
+		log.WithFields(map[string]interface{}{"transaction_id": txid, "uuid": uuid}).Info("republish")
+
+		hash, err := publisher.Republish(ctx, uuid)
+		if status, _, ok := knownPublishError(err); ok {
+			writePublishError(w, status, err.Error(), err)
+			return
+		}
+		if err != nil {
+			mlog.WithError(err).Error("Unable to republish annotations")
+			writePublishError(w, http.StatusServiceUnavailable, "Unable to republish annotations", err)
+			return
+		}
+
+		w.Header().Set(annotations.DocumentHashHeader, hash)
+		writeMsg(w, http.StatusAccepted, "Republish accepted")
+	}
+}
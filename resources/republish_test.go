@@ -0,0 +1,62 @@
+package resources
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepublish(t *testing.T) {
+	tests := map[string]struct {
+		hash           string
+		err            error
+		expectedStatus int
+		expectedMsg    string
+	}{
+		"success": {
+			hash:           "hash",
+			expectedStatus: http.StatusAccepted,
+			expectedMsg:    "Republish accepted",
+		},
+		"never published": {
+			err:            annotations.ErrNotPublished,
+			expectedStatus: http.StatusNotFound,
+			expectedMsg:    "Published annotations were not found",
+		},
+		"failure": {
+			err:            errors.New("publish to upp returned a 500 status code"),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedMsg:    "Unable to republish annotations",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := vestigo.NewRouter()
+			pub := &mockPublisher{}
+			pub.On("Republish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return(test.hash, test.err)
+			r.Post("/content/:uuid/annotations/republish", Republish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/content/a-valid-uuid/annotations/republish", nil)
+
+			r.ServeHTTP(w, req)
+
+			resp, err := marshal(w.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedStatus, w.Code)
+			assert.Equal(t, test.expectedMsg, resp["message"])
+			assert.Equal(t, test.hash, w.Header().Get(annotations.DocumentHashHeader))
+
+			pub.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package resources
+
+import (
+	"net/http"
+
+	"github.com/Financial-Times/annotations-publisher/retryqueue"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+)
+
+// ListPublishRetries lists the failed UPP publishes waiting to be retried
+func ListPublishRetries(queue *retryqueue.Queue, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries, err := queue.List()
+		if err != nil {
+			log.WithError(err).Error("failed to list publish retries")
+			writeMsg(w, http.StatusInternalServerError, "Failed to read the publish retry queue")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"retries": entries})
+	}
+}
+
+// RetryPublish immediately retries the failed UPP publish of the content
+func RetryPublish(queue *retryqueue.Queue, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry, published, err := queue.Retry(vestigo.Param(r, "uuid"))
+		if err == retryqueue.ErrEntryNotFound {
+			writeMsg(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("failed to update publish retry queue")
+			writeMsg(w, http.StatusInternalServerError, "Failed to update the publish retry queue")
+			return
+		}
+		if published {
+			writeMsg(w, http.StatusOK, "Publish retried successfully")
+			return
+		}
+
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"message": "Publish retry failed", "retry": entry})
+	}
+}
+
+// DiscardPublishRetry removes the failed UPP publish of the content from the queue without retrying it
+func DiscardPublishRetry(queue *retryqueue.Queue, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := queue.Discard(vestigo.Param(r, "uuid"))
+		if err == retryqueue.ErrEntryNotFound {
+			writeMsg(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("failed to discard publish retry")
+			writeMsg(w, http.StatusInternalServerError, "Failed to update the publish retry queue")
+			return
+		}
+		writeMsg(w, http.StatusOK, "Publish retry discarded")
+	}
+}
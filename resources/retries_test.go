@@ -0,0 +1,112 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/retryqueue"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRetryRouter(t *testing.T, pub *mockPublisher) (*vestigo.Router, *retryqueue.Queue) {
+	log := logger.NewUPPLogger("test", "DEBUG")
+	store, err := retryqueue.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	queue := retryqueue.NewQueue(store, retryqueue.Policy{InitialBackoff: time.Hour, MaxBackoff: time.Hour, Timeout: timeout}, log)
+	queue.Start(pub, nil, time.Hour)
+	t.Cleanup(queue.Stop)
+
+	r := vestigo.NewRouter()
+	r.Get("/__publish-retries", ListPublishRetries(queue, log))
+	r.Post("/__publish-retries/:uuid/retry", RetryPublish(queue, log))
+	r.Delete("/__publish-retries/:uuid", DiscardPublishRetry(queue, log))
+	return r, queue
+}
+
+func TestListPublishRetries(t *testing.T) {
+	r, queue := newTestRetryRouter(t, &mockPublisher{})
+	require.NoError(t, queue.Add(context.Background(), "a-valid-uuid", map[string]interface{}{}, errors.New("eek")))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/__publish-retries", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Retries []retryqueue.Entry `json:"retries"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Retries, 1)
+	assert.Equal(t, "a-valid-uuid", resp.Retries[0].UUID)
+	assert.Equal(t, "eek", resp.Retries[0].LastError)
+}
+
+func TestRetryPublish(t *testing.T) {
+	pub := &mockPublisher{}
+	pub.On("Publish", mock.Anything, "a-valid-uuid", mock.Anything).Return(nil)
+
+	r, queue := newTestRetryRouter(t, pub)
+	require.NoError(t, queue.Add(context.Background(), "a-valid-uuid", map[string]interface{}{}, errors.New("eek")))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/__publish-retries/"+"a-valid-uuid"+"/retry", nil))
+
+	resp, err := marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Publish retried successfully", resp["message"])
+
+	entries, err := queue.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	pub.AssertExpectations(t)
+}
+
+func TestRetryPublishFailsAgain(t *testing.T) {
+	pub := &mockPublisher{}
+	pub.On("Publish", mock.Anything, "a-valid-uuid", mock.Anything).Return(errors.New("still failing"))
+
+	r, queue := newTestRetryRouter(t, pub)
+	require.NoError(t, queue.Add(context.Background(), "a-valid-uuid", map[string]interface{}{}, errors.New("eek")))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/__publish-retries/"+"a-valid-uuid"+"/retry", nil))
+
+	resp, err := marshal(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "Publish retry failed", resp["message"])
+	assert.Equal(t, "still failing", resp["retry"].(map[string]interface{})["lastError"])
+
+	pub.AssertExpectations(t)
+}
+
+func TestRetryPublishNotFound(t *testing.T) {
+	r, _ := newTestRetryRouter(t, &mockPublisher{})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/__publish-retries/unknown/retry", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDiscardPublishRetry(t *testing.T) {
+	r, queue := newTestRetryRouter(t, &mockPublisher{})
+	require.NoError(t, queue.Add(context.Background(), "a-valid-uuid", map[string]interface{}{}, errors.New("eek")))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/__publish-retries/"+"a-valid-uuid", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/__publish-retries/"+"a-valid-uuid", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,43 @@
+package resources
+
+import (
+	"net/http"
+
+	"github.com/Financial-Times/annotations-publisher/tracing"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/husobee/vestigo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Financial-Times/annotations-publisher/resources")
+
+// traced handles the request with next inside a span, which continues the trace of the caller if it sent a traceparent header
+func traced(name string, next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := tid.TransactionAwareContext(tracing.Extract(r.Context(), r), tid.GetTransactionIDFromRequest(r))
+		ctx, span := tracing.Start(ctx, tracer, name, vestigo.Param(r, "uuid"), trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next(sw, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", sw.status))
+		if sw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+	}
+}
+
+// statusWriter keeps the status written to the response, which is 200 unless another is written
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,156 @@
+package resources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/jobs"
+	"github.com/Financial-Times/annotations-publisher/tracing"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPublishContinuesCallerTrace(t *testing.T) {
+	recorder := recordSpans(t)
+
+	r := vestigo.NewRouter()
+	pub := &mockPublisher{}
+	pub.On("SaveAndPublish", mock.MatchedBy(func(ctx context.Context) bool {
+		return trace.SpanContextFromContext(ctx).TraceID().String() == "4bf92f3577b34da6a3ce929d0e0e4736"
+	}), "a-valid-uuid", "hash", mock.Anything).Return("new-hash", nil)
+
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish", strings.NewReader(testPublishBody))
+	req.Header.Add(annotations.PreviousDocumentHashHeader, "hash")
+	req.Header.Add(tid.TransactionIDHeader, "tid_test")
+	req.Header.Add("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	pub.AssertExpectations(t)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "Publish", spans[0].Name())
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Contains(t, spans[0].Attributes(), tracing.TransactionIDKey.String("tid_test"))
+	assert.Contains(t, spans[0].Attributes(), tracing.UUIDKey.String("a-valid-uuid"))
+}
+
+func TestAsyncPublishContinuesCallerTrace(t *testing.T) {
+	recorder := recordSpans(t)
+
+	log := logger.NewUPPLogger("test", "DEBUG")
+	pub := &mockPublisher{}
+	pub.On("PublishFromStore", mock.MatchedBy(func(ctx context.Context) bool {
+		return trace.SpanContextFromContext(ctx).TraceID().String() == "4bf92f3577b34da6a3ce929d0e0e4736"
+	}), "a-valid-uuid").Return("new-hash", nil)
+
+	queue := jobs.NewQueue(pub, 10, timeout, time.Hour, log)
+	queue.Start(1)
+
+	r := vestigo.NewRouter()
+	r.Post("/drafts/content/:uuid/annotations/publish", Publish(pub, timeout, log, WithPublishJobs(queue)))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/drafts/content/a-valid-uuid/annotations/publish?async=true&fromStore=true", nil)
+	req.Header.Add(tid.TransactionIDHeader, "tid_test")
+	req.Header.Add("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	queue.Stop()
+	pub.AssertExpectations(t)
+
+	// the job may finish before the span of the request has ended
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	request, job := spans[0], spans[1]
+	if request.Name() != "Publish" {
+		request, job = job, request
+	}
+	assert.Equal(t, "Publish", request.Name())
+	assert.Equal(t, "PublishJob", job.Name())
+	assert.Equal(t, trace.SpanKindConsumer, job.SpanKind())
+	assert.Equal(t, request.SpanContext().TraceID(), job.SpanContext().TraceID())
+	assert.Equal(t, request.SpanContext().SpanID(), job.Parent().SpanID())
+	assert.Contains(t, job.Attributes(), tracing.TransactionIDKey.String("tid_test"))
+	assert.Contains(t, job.Attributes(), tracing.UUIDKey.String("a-valid-uuid"))
+}
+
+var (
+	testSpans     = &testSpanProcessor{}
+	setupTestOnce sync.Once
+)
+
+// recordSpans records the spans ended by the test. The global tracer provider is only set once,
+// since the tracers of every package keep using the first one.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	setupTestOnce.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(testSpans)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	testSpans.set(recorder)
+	t.Cleanup(func() { testSpans.set(nil) })
+	return recorder
+}
+
+// testSpanProcessor sends spans on to the recorder of the test which is running
+type testSpanProcessor struct {
+	mutex    sync.Mutex
+	recorder *tracetest.SpanRecorder
+}
+
+func (p *testSpanProcessor) set(recorder *tracetest.SpanRecorder) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.recorder = recorder
+}
+
+func (p *testSpanProcessor) current() *tracetest.SpanRecorder {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.recorder
+}
+
+func (p *testSpanProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	if recorder := p.current(); recorder != nil {
+		recorder.OnStart(ctx, span)
+	}
+}
+
+func (p *testSpanProcessor) OnEnd(span sdktrace.ReadOnlySpan) {
+	if recorder := p.current(); recorder != nil {
+		recorder.OnEnd(span)
+	}
+}
+
+func (p *testSpanProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *testSpanProcessor) ForceFlush(context.Context) error {
+	return nil
+}
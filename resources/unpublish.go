@@ -0,0 +1,42 @@
+package resources
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/husobee/vestigo"
+)
+
+// Unpublish removes the published annotations of a piece of content from PAC and UPP
+func Unpublish(publisher annotations.Publisher, httpTimeOut time.Duration, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txid := tid.GetTransactionIDFromRequest(r)
+		mlog := log.WithField(tid.TransactionIDHeader, txid)
+		ctx, cancel := context.WithTimeout(requestContext(r, txid), httpTimeOut)
+		defer cancel()
+
+		uuid := vestigo.Param(r, "uuid")
+		if uuid == "" {
+			writeMsg(w, http.StatusBadRequest, "Please specify a valid uuid in the request")
+			return
+		}
+		log.WithFields(map[string]interface{}{"transaction_id": txid, "uuid": uuid}).Info("unpublish")
+
+		err := publisher.Unpublish(ctx, uuid)
+		if status, _, ok := knownPublishError(err); ok {
+			writePublishError(w, status, err.Error(), err)
+			return
+		}
+		if err != nil {
+			mlog.WithError(err).Error("Unable to unpublish annotations")
+			writePublishError(w, http.StatusServiceUnavailable, "Unable to unpublish annotations", err)
+			return
+		}
+
+		writeMsg(w, http.StatusAccepted, "Unpublish accepted")
+	}
+}
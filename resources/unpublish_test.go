@@ -0,0 +1,59 @@
+package resources
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/husobee/vestigo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnpublish(t *testing.T) {
+	tests := map[string]struct {
+		err            error
+		expectedStatus int
+		expectedMsg    string
+	}{
+		"success": {
+			expectedStatus: http.StatusAccepted,
+			expectedMsg:    "Unpublish accepted",
+		},
+		"timeout": {
+			err:            annotations.ErrServiceTimeout,
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedMsg:    "Downstream service timed out",
+		},
+		"failure": {
+			err:            errors.New("publish to upp returned a 500 status code"),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedMsg:    "Unable to unpublish annotations",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := vestigo.NewRouter()
+			pub := &mockPublisher{}
+			pub.On("Unpublish", mock.AnythingOfType("*context.timerCtx"), "a-valid-uuid").Return(test.err)
+			r.Delete("/drafts/content/:uuid/annotations/publish", Unpublish(pub, timeout, logger.NewUPPLogger("test", "DEBUG")))
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("DELETE", "/drafts/content/a-valid-uuid/annotations/publish", nil)
+
+			r.ServeHTTP(w, req)
+
+			resp, err := marshal(w.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedStatus, w.Code)
+			assert.Equal(t, test.expectedMsg, resp["message"])
+
+			pub.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package retryqueue
+
+import (
+	"context"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+)
+
+// Publisher sends annotations to UPP
+type Publisher interface {
+	Publish(ctx context.Context, uuid string, body map[string]interface{}) error
+}
+
+// Policy configures how often failed publishes are retried
+type Policy struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxAttempts is the number of automatic retries before an entry is left for manual action. Zero retries forever.
+	MaxAttempts int
+	Timeout     time.Duration
+}
+
+// Locker serialises the replays of a piece of content with its publishes, so a replay never overtakes a newer publish.
+// annotations.KeyedLocker implements it.
+type Locker interface {
+	Lock(ctx context.Context, key string) (func(), error)
+}
+
+type noLocker struct{}
+
+func (noLocker) Lock(context.Context, string) (func(), error) {
+	return func() {}, nil
+}
+
+// Queue replays failed UPP publishes with exponential backoff until they succeed. It holds at most one entry for every piece of content.
+type Queue struct {
+	store     Store
+	policy    Policy
+	publisher Publisher
+	locker    Locker
+	log       *logger.UPPLogger
+
+	stop chan struct{}
+	done chan struct{}
+	now  func() time.Time
+}
+
+// NewQueue returns a Queue backed by the given store. Call Start to begin replaying entries.
+func NewQueue(store Store, policy Policy, log *logger.UPPLogger) *Queue {
+	return &Queue{
+		store:  store,
+		policy: policy,
+		locker: noLocker{},
+		log:    log,
+		now:    time.Now,
+	}
+}
+
+// Add records a failed publish, replacing the entry of the same content, since it holds an older version of its annotations.
+// It implements annotations.FailedPublishQueue, whose caller holds the publish lock of the content.
+func (q *Queue) Add(ctx context.Context, contentUUID string, body map[string]interface{}, cause error) error {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	now := q.now()
+
+	return q.store.Put(Entry{
+		UUID:          contentUUID,
+		TransactionID: txid,
+		Body:          body,
+		LastError:     cause.Error(),
+		Created:       now,
+		NextAttempt:   now.Add(q.policy.InitialBackoff),
+	})
+}
+
+// Resolve removes the entry of the content, since a later publish or unpublish has brought UPP in line with the published annotations.
+// It implements annotations.FailedPublishQueue, whose caller holds the publish lock of the content.
+func (q *Queue) Resolve(_ context.Context, contentUUID string) error {
+	if err := q.store.Delete(contentUUID); err != nil && err != ErrEntryNotFound {
+		return err
+	}
+	return nil
+}
+
+// List returns all the entries in the queue
+func (q *Queue) List() ([]Entry, error) {
+	return q.store.List()
+}
+
+// Discard removes the entry of the content without publishing it
+func (q *Queue) Discard(contentUUID string) error {
+	unlock, err := q.lock(contentUUID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return q.store.Delete(contentUUID)
+}
+
+// Retry replays the entry of the content immediately, whether or not it is due.
+// It reports whether the publish succeeded, and returns the updated entry if it failed again.
+func (q *Queue) Retry(contentUUID string) (Entry, bool, error) {
+	return q.replay(contentUUID, false)
+}
+
+// Start replays the due entries every interval using the provided publisher, until Stop is called.
+// Replays hold the lock of their content in the locker, which must be the one used by the publishes.
+func (q *Queue) Start(publisher Publisher, locker Locker, interval time.Duration) {
+	q.publisher = publisher
+	if locker != nil {
+		q.locker = locker
+	}
+	q.stop = make(chan struct{})
+	q.done = make(chan struct{})
+
+	go func() {
+		defer close(q.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-q.stop:
+				return
+			case <-ticker.C:
+				q.replayDue()
+			}
+		}
+	}()
+}
+
+// Stop stops replaying entries
+func (q *Queue) Stop() {
+	close(q.stop)
+	<-q.done
+}
+
+func (q *Queue) replayDue() {
+	entries, err := q.store.List()
+	if err != nil {
+		q.log.WithError(err).Error("failed to read publish retry queue")
+		return
+	}
+
+	for _, listed := range entries {
+		if listed.Exhausted || listed.NextAttempt.After(q.now()) {
+			continue
+		}
+
+		// the entry may have been resolved, replaced or discarded since it was listed
+		if _, _, err := q.replay(listed.UUID, true); err != nil && err != ErrEntryNotFound {
+			q.log.WithError(err).WithUUID(listed.UUID).Error("failed to replay publish retry")
+		}
+	}
+}
+
+// replay publishes the entry of the content while holding its publish lock, and removes the entry on success.
+// The entry is read once the lock is held, so an entry resolved by a newer publish is never sent.
+func (q *Queue) replay(contentUUID string, onlyIfDue bool) (Entry, bool, error) {
+	unlock, err := q.lock(contentUUID)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	defer unlock()
+
+	entry, err := q.store.Get(contentUUID)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if onlyIfDue && (entry.Exhausted || entry.NextAttempt.After(q.now())) {
+		return entry, false, nil
+	}
+
+	mlog := q.log.WithTransactionID(entry.TransactionID).WithUUID(entry.UUID)
+	ctx, cancel := context.WithTimeout(tid.TransactionAwareContext(context.Background(), entry.TransactionID), q.policy.Timeout)
+	defer cancel()
+
+	err = q.publisher.Publish(ctx, entry.UUID, entry.Body)
+	if err == nil {
+		mlog.Info("retried upp publish succeeded")
+		return Entry{}, true, q.store.Delete(entry.UUID)
+	}
+
+	entry.Attempts++
+	entry.LastError = err.Error()
+	entry.NextAttempt = q.now().Add(q.backoff(entry.Attempts))
+	entry.Exhausted = q.policy.MaxAttempts > 0 && entry.Attempts >= q.policy.MaxAttempts
+
+	mlog.WithError(err).WithField("attempts", entry.Attempts).Warn("retried upp publish failed")
+	return entry, false, q.store.Put(entry)
+}
+
+// lock waits for the publish lock of the content, for at most the timeout of a replay
+func (q *Queue) lock(contentUUID string) (func(), error) {
+	ctx := context.Background()
+	if q.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.policy.Timeout)
+		defer cancel()
+	}
+	return q.locker.Lock(ctx, contentUUID)
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	backoff := q.policy.InitialBackoff
+	for i := 0; i < attempts && backoff < q.policy.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > q.policy.MaxBackoff {
+		return q.policy.MaxBackoff
+	}
+	return backoff
+}
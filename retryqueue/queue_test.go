@@ -0,0 +1,289 @@
+package retryqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = Policy{
+	InitialBackoff: time.Minute,
+	MaxBackoff:     5 * time.Minute,
+	MaxAttempts:    3,
+	Timeout:        time.Second,
+}
+
+func newTestQueue(t *testing.T, now time.Time) *Queue {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	q := NewQueue(store, testPolicy, logger.NewUPPLogger("test", "DEBUG"))
+	q.now = func() time.Time { return now }
+	return q
+}
+
+func TestAdd(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	q := newTestQueue(t, now)
+
+	body := map[string]interface{}{"uuid": "a-valid-uuid"}
+	err := q.Add(tid.TransactionAwareContext(context.Background(), "tid_test"), "a-valid-uuid", body, errors.New("eek"))
+	require.NoError(t, err)
+
+	entries, err := q.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "a-valid-uuid", entries[0].UUID)
+	assert.Equal(t, "tid_test", entries[0].TransactionID)
+	assert.Equal(t, body, entries[0].Body)
+	assert.Equal(t, "eek", entries[0].LastError)
+	assert.Equal(t, now.Add(time.Minute), entries[0].NextAttempt)
+}
+
+func TestAddReplacesEntryOfSameContent(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	q := newTestQueue(t, now)
+	require.NoError(t, q.store.Put(Entry{UUID: "a-valid-uuid", Attempts: 2, NextAttempt: now}))
+	require.NoError(t, q.store.Put(Entry{UUID: "another-uuid", NextAttempt: now}))
+
+	body := map[string]interface{}{"uuid": "a-valid-uuid", "annotations": []interface{}{}}
+	require.NoError(t, q.Add(context.Background(), "a-valid-uuid", body, errors.New("eek")))
+
+	entries, err := q.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		if entry.UUID == "a-valid-uuid" {
+			assert.Equal(t, body, entry.Body)
+			assert.Zero(t, entry.Attempts)
+		}
+	}
+}
+
+func TestResolvedEntryIsNotReplayed(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	q := newTestQueue(t, now)
+
+	staleBody := map[string]interface{}{"uuid": "a-valid-uuid", "annotations": []interface{}{"stale"}}
+	require.NoError(t, q.Add(context.Background(), "a-valid-uuid", staleBody, errors.New("eek")))
+	require.NoError(t, q.Add(context.Background(), "another-uuid", map[string]interface{}{"uuid": "another-uuid"}, errors.New("eek")))
+
+	// a later publish of the content succeeded
+	require.NoError(t, q.Resolve(context.Background(), "a-valid-uuid"))
+
+	pub := &mockPublisher{}
+	pub.On("Publish", mock.Anything, "another-uuid", mock.Anything).Return(nil)
+	q.publisher = pub
+	q.now = func() time.Time { return now.Add(time.Hour) }
+
+	q.replayDue()
+
+	entries, err := q.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	pub.AssertExpectations(t)
+	pub.AssertNotCalled(t, "Publish", mock.Anything, "a-valid-uuid", mock.Anything)
+}
+
+func TestReplayDuePublishesAndRemovesEntries(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	q := newTestQueue(t, now)
+
+	require.NoError(t, q.store.Put(Entry{UUID: "due-uuid", TransactionID: "tid_test", NextAttempt: now}))
+	require.NoError(t, q.store.Put(Entry{UUID: "later-uuid", Created: now.Add(-2 * time.Minute), NextAttempt: now.Add(time.Second)}))
+	require.NoError(t, q.store.Put(Entry{UUID: "exhausted-uuid", Created: now.Add(-time.Minute), NextAttempt: now, Exhausted: true}))
+
+	pub := &mockPublisher{}
+	pub.On("Publish", mock.Anything, "due-uuid", mock.Anything).Return(nil)
+	q.publisher = pub
+
+	q.replayDue()
+
+	entries, err := q.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "later-uuid", entries[0].UUID)
+	assert.Equal(t, "exhausted-uuid", entries[1].UUID)
+
+	pub.AssertExpectations(t)
+}
+
+func TestReplayFailureBacksOffExponentially(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	q := newTestQueue(t, now)
+	require.NoError(t, q.store.Put(Entry{UUID: "a-valid-uuid", NextAttempt: now}))
+
+	pub := &mockPublisher{}
+	pub.On("Publish", mock.Anything, "a-valid-uuid", mock.Anything).Return(errors.New("eek"))
+	q.publisher = pub
+
+	expectedBackoffs := []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute}
+	for i, backoff := range expectedBackoffs {
+		entry, published, err := q.Retry("a-valid-uuid")
+		require.NoError(t, err)
+		assert.False(t, published)
+		assert.Equal(t, i+1, entry.Attempts)
+		assert.Equal(t, "eek", entry.LastError)
+		assert.Equal(t, now.Add(backoff), entry.NextAttempt)
+		assert.Equal(t, i+1 == testPolicy.MaxAttempts, entry.Exhausted)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	q := newTestQueue(t, time.Now())
+	require.NoError(t, q.store.Put(Entry{UUID: "a-valid-uuid", TransactionID: "tid_test", Exhausted: true}))
+
+	pub := &mockPublisher{}
+	pub.On("Publish", mock.MatchedBy(func(ctx context.Context) bool {
+		txid, _ := tid.GetTransactionIDFromContext(ctx)
+		return txid == "tid_test"
+	}), "a-valid-uuid", mock.Anything).Return(nil)
+	q.publisher = pub
+
+	_, published, err := q.Retry("a-valid-uuid")
+	require.NoError(t, err)
+	assert.True(t, published)
+
+	_, _, err = q.Retry("a-valid-uuid")
+	assert.Equal(t, ErrEntryNotFound, err)
+
+	pub.AssertExpectations(t)
+}
+
+func TestDiscard(t *testing.T) {
+	q := newTestQueue(t, time.Now())
+	require.NoError(t, q.store.Put(Entry{UUID: "a-valid-uuid"}))
+
+	require.NoError(t, q.Discard("a-valid-uuid"))
+	assert.Equal(t, ErrEntryNotFound, q.Discard("a-valid-uuid"))
+}
+
+func TestStartReplaysInTheBackground(t *testing.T) {
+	q := newTestQueue(t, time.Now())
+	require.NoError(t, q.store.Put(Entry{UUID: "a-valid-uuid"}))
+
+	pub := &mockPublisher{}
+	pub.On("Publish", mock.Anything, "a-valid-uuid", mock.Anything).Return(nil)
+
+	q.Start(pub, nil, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		entries, err := q.List()
+		return err == nil && len(entries) == 0
+	}, time.Second, 10*time.Millisecond)
+	q.Stop()
+
+	pub.AssertExpectations(t)
+}
+
+func TestReplayWaitsForNewerPublishOfTheContent(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	q := newTestQueue(t, now)
+	locker := &testLocker{}
+	q.locker = locker
+	require.NoError(t, q.Add(context.Background(), "a-valid-uuid", map[string]interface{}{"annotations": []interface{}{"stale"}}, errors.New("eek")))
+
+	pub := &mockPublisher{}
+	q.publisher = pub
+	q.now = func() time.Time { return now.Add(time.Hour) }
+
+	// a newer publish of the content holds its lock while the replay is due
+	unlock, err := locker.Lock(context.Background(), "a-valid-uuid")
+	require.NoError(t, err)
+	replayed := make(chan struct{})
+	go func() {
+		q.replayDue()
+		close(replayed)
+	}()
+
+	require.Eventually(t, func() bool { return locker.waiting() == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, q.Resolve(context.Background(), "a-valid-uuid"))
+	unlock()
+	<-replayed
+
+	pub.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSlowReplayDoesNotBlockOtherContent(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	q := newTestQueue(t, now)
+	require.NoError(t, q.Add(context.Background(), "slow-uuid", map[string]interface{}{}, errors.New("eek")))
+	require.NoError(t, q.Add(context.Background(), "another-uuid", map[string]interface{}{}, errors.New("eek")))
+
+	publishing := make(chan struct{})
+	release := make(chan struct{})
+	pub := &mockPublisher{}
+	pub.On("Publish", mock.Anything, "slow-uuid", mock.Anything).Run(func(mock.Arguments) {
+		close(publishing)
+		<-release
+	}).Return(nil)
+	q.publisher = pub
+
+	retried := make(chan struct{})
+	go func() {
+		_, _, err := q.Retry("slow-uuid")
+		assert.NoError(t, err)
+		close(retried)
+	}()
+	<-publishing
+
+	// the replay in progress does not stop publishes of other content from adding or resolving their entries
+	require.NoError(t, q.Add(context.Background(), "third-uuid", map[string]interface{}{}, errors.New("eek")))
+	require.NoError(t, q.Resolve(context.Background(), "another-uuid"))
+	close(release)
+	<-retried
+
+	entries, err := q.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "third-uuid", entries[0].UUID)
+}
+
+// testLocker is a Locker for a single piece of content, which reports how many callers are waiting for it
+type testLocker struct {
+	mutex   sync.Mutex
+	locked  chan struct{}
+	waiters int
+}
+
+func (l *testLocker) Lock(ctx context.Context, _ string) (func(), error) {
+	l.mutex.Lock()
+	if l.locked == nil {
+		l.locked = make(chan struct{}, 1)
+	}
+	l.waiters++
+	l.mutex.Unlock()
+
+	select {
+	case l.locked <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	l.mutex.Lock()
+	l.waiters--
+	l.mutex.Unlock()
+	return func() { <-l.locked }, nil
+}
+
+func (l *testLocker) waiting() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.waiters
+}
+
+type mockPublisher struct {
+	mock.Mock
+}
+
+func (m *mockPublisher) Publish(ctx context.Context, uuid string, body map[string]interface{}) error {
+	args := m.Called(ctx, uuid, body)
+	return args.Error(0)
+}
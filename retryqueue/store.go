@@ -0,0 +1,176 @@
+package retryqueue
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrEntryNotFound occurs when the requested entry is not in the store
+var ErrEntryNotFound = errors.New("retry entry not found")
+
+// Entry is a UPP publish waiting to be retried. It is identified by the UUID of its content.
+type Entry struct {
+	UUID          string                 `json:"uuid"`
+	TransactionID string                 `json:"transactionId"`
+	Body          map[string]interface{} `json:"body"`
+	Attempts      int                    `json:"attempts"`
+	LastError     string                 `json:"lastError"`
+	Created       time.Time              `json:"created"`
+	NextAttempt   time.Time              `json:"nextAttempt"`
+	Exhausted     bool                   `json:"exhausted"`
+}
+
+// Store persists the entries of the retry queue, keyed by the UUID of their content
+type Store interface {
+	// Put adds the entry, replacing any entry of the same content
+	Put(entry Entry) error
+	Get(uuid string) (Entry, error)
+	Delete(uuid string) error
+	List() ([]Entry, error)
+}
+
+type fileStore struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// NewFileStore returns a Store which keeps the entry of every piece of content as a JSON file named after its UUID in the given directory
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &fileStore{dir: dir}
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate moves the entries written before entries were named after their content to the file of their content,
+// keeping the most recent entry of every piece of content
+func (s *fileStore) migrate() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		entry, err := s.read(f)
+		if err != nil {
+			return err
+		}
+		path := s.path(entry.UUID)
+		if f == path {
+			continue
+		}
+
+		current, err := s.read(path)
+		switch {
+		case err == ErrEntryNotFound || (err == nil && current.Created.Before(entry.Created)):
+			err = os.Rename(f, path)
+		case err == nil:
+			err = os.Remove(f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileStore) Put(entry Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so a crash never leaves a partially written entry behind
+	tmp, err := os.CreateTemp(s.dir, "entry.*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(entry.UUID))
+}
+
+func (s *fileStore) Get(uuid string) (Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.read(s.path(uuid))
+}
+
+func (s *fileStore) Delete(uuid string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := os.Remove(s.path(uuid))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrEntryNotFound
+	}
+	return err
+}
+
+// List returns every entry in the store, oldest first
+func (s *fileStore) List() ([]Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(files))
+	for _, f := range files {
+		entry, err := s.read(f)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+	return entries, nil
+}
+
+func (s *fileStore) read(path string) (Entry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Entry{}, ErrEntryNotFound
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var entry Entry
+	err = json.Unmarshal(data, &entry)
+	return entry, err
+}
+
+func (s *fileStore) path(uuid string) string {
+	// uuids come from requests, so never let one escape the store directory
+	return filepath.Join(s.dir, strings.ReplaceAll(filepath.Base(uuid), ".", "_")+".json")
+}
@@ -0,0 +1,96 @@
+package retryqueue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	older := Entry{UUID: "a-valid-uuid", TransactionID: "tid_test", Body: map[string]interface{}{"annotations": []interface{}{}}, Created: now.Add(-time.Minute), NextAttempt: now}
+	newer := Entry{UUID: "another-valid-uuid", TransactionID: "tid_test", Created: now, NextAttempt: now}
+
+	require.NoError(t, store.Put(newer))
+	require.NoError(t, store.Put(older))
+
+	entries, err := store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{older, newer}, entries)
+
+	older.Attempts = 1
+	require.NoError(t, store.Put(older))
+
+	actual, err := store.Get("a-valid-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, older, actual)
+
+	require.NoError(t, store.Delete("a-valid-uuid"))
+	_, err = store.Get("a-valid-uuid")
+	assert.Equal(t, ErrEntryNotFound, err)
+	assert.Equal(t, ErrEntryNotFound, store.Delete("a-valid-uuid"))
+
+	entries, err = store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{newer}, entries)
+}
+
+func TestFileStoreIsDurable(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	entry := Entry{UUID: "a-valid-uuid", Created: time.Now().UTC().Truncate(time.Second)}
+	require.NoError(t, store.Put(entry))
+
+	reopened, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	actual, err := reopened.Get("a-valid-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, entry, actual)
+}
+
+func TestFileStoreDoesNotEscapeDirectory(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = store.Get("../../etc/passwd")
+	assert.Equal(t, ErrEntryNotFound, err)
+}
+
+func TestFileStoreMigratesEntriesNamedByID(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC().Truncate(time.Second)
+	entries := map[string]string{
+		"first-id.json":  `{"id":"first-id","uuid":"a-valid-uuid","lastError":"older","created":"` + now.Add(-time.Minute).Format(time.RFC3339) + `"}`,
+		"second-id.json": `{"id":"second-id","uuid":"a-valid-uuid","lastError":"newer","created":"` + now.Format(time.RFC3339) + `"}`,
+		"third-id.json":  `{"id":"third-id","uuid":"another-valid-uuid","lastError":"eek","created":"` + now.Format(time.RFC3339) + `"}`,
+	}
+	for name, content := range entries {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	listed, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+
+	entry, err := store.Get("a-valid-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, "newer", entry.LastError)
+
+	require.NoError(t, store.Delete("another-valid-uuid"))
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a-valid-uuid.json")}, files)
+}
@@ -0,0 +1,143 @@
+package telemetry
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/breaker"
+	"github.com/Financial-Times/annotations-publisher/bulkhead"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "annotations_publisher"
+
+// Metrics is an annotations.PublishObserver which keeps Prometheus metrics of the publishes, and of the responses of the downstream services
+type Metrics struct {
+	registry            *prometheus.Registry
+	stageDuration       *prometheus.HistogramVec
+	publishDuration     *prometheus.HistogramVec
+	publishes           *prometheus.CounterVec
+	downstreamResponses *prometheus.CounterVec
+}
+
+// NewMetrics returns Metrics registered in a new registry, together with the metrics of the Go runtime and the process
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		stageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "publish_stage_duration_seconds",
+			Help:      "Duration of the reads and writes of the annotations and of the UPP publish made by publishes, by stage and outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"stage", "outcome"}),
+		publishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "publish_duration_seconds",
+			Help:      "Duration of publishes, including the wait for other publishes of the same content, by operation and outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "outcome"}),
+		publishes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publishes_total",
+			Help:      "Number of publishes, by operation, outcome and type of error.",
+		}, []string{"operation", "outcome", "error"}),
+		downstreamResponses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "downstream_responses_total",
+			Help:      "Number of responses of the downstream services, by service and status code. Requests which failed without a response have the code error.",
+		}, []string{"downstream", "code"}),
+	}
+	m.registry.MustRegister(
+		m.stageDuration,
+		m.publishDuration,
+		m.publishes,
+		m.downstreamResponses,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveStage records the duration of a stage of a publish
+func (m *Metrics) ObserveStage(stage annotations.Stage, duration time.Duration, err error) {
+	m.stageDuration.WithLabelValues(string(stage), outcome(err)).Observe(duration.Seconds())
+}
+
+// ObservePublish counts the publish attempt by its outcome and type of error, and records its duration
+func (m *Metrics) ObservePublish(attempt annotations.PublishAttempt) {
+	op := operation(attempt)
+	m.publishes.WithLabelValues(op, outcome(attempt.Err), errorType(attempt.Err)).Inc()
+	m.publishDuration.WithLabelValues(op, outcome(attempt.Err)).Observe(attempt.Latency.Seconds())
+}
+
+// Transport returns a http.RoundTripper which counts the responses of the downstream service to requests sent to next
+func (m *Metrics) Transport(downstream string, next http.RoundTripper) http.RoundTripper {
+	return &transport{responses: m.downstreamResponses.MustCurryWith(prometheus.Labels{"downstream": downstream}), next: next}
+}
+
+type transport struct {
+	responses *prometheus.CounterVec
+	next      http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.responses.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	t.responses.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+	return resp, nil
+}
+
+func operation(attempt annotations.PublishAttempt) string {
+	switch {
+	case attempt.FromStore:
+		return "publish_from_store"
+	case attempt.Unpublish:
+		return "unpublish"
+	case attempt.Republish:
+		return "republish"
+	}
+	return "save_and_publish"
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// errorType groups the errors of publishes into the categories callers act on
+func errorType(err error) string {
+	switch {
+	case err == nil:
+		return "none"
+	case errors.Is(err, annotations.ErrServiceTimeout):
+		return "timeout"
+	case errors.Is(err, annotations.ErrInvalidAuthentication):
+		return "auth"
+	case errors.Is(err, annotations.ErrDraftNotFound), errors.Is(err, annotations.ErrNotPublished):
+		return "not_found"
+	case errors.Is(err, annotations.ErrConflict):
+		return "conflict"
+	case errors.Is(err, annotations.ErrInvalidAnnotations):
+		return "invalid"
+	case errors.Is(err, breaker.ErrOpen):
+		return "circuit_open"
+	case errors.Is(err, bulkhead.ErrFull):
+		return "bulkhead_full"
+	}
+	return "other"
+}
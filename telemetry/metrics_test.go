@@ -0,0 +1,79 @@
+package telemetry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/annotations-publisher/annotations"
+	"github.com/Financial-Times/annotations-publisher/breaker"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObservePublish(t *testing.T) {
+	m := NewMetrics()
+
+	m.ObservePublish(annotations.PublishAttempt{FromStore: true, Latency: time.Second})
+	m.ObservePublish(annotations.PublishAttempt{FromStore: true, Err: annotations.ErrServiceTimeout})
+	m.ObservePublish(annotations.PublishAttempt{Err: &annotations.ConflictError{Cause: annotations.ErrConflict}})
+	m.ObservePublish(annotations.PublishAttempt{Republish: true, Err: annotations.ErrNotPublished})
+	m.ObservePublish(annotations.PublishAttempt{Unpublish: true, Err: &url.Error{Op: "Post", URL: "http://upp", Err: &breaker.OpenError{Name: "upp-publish"}}})
+	m.ObservePublish(annotations.PublishAttempt{Err: errors.New("publish to upp returned a 500 status code")})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.publishes.WithLabelValues("publish_from_store", "success", "none")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.publishes.WithLabelValues("publish_from_store", "failure", "timeout")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.publishes.WithLabelValues("save_and_publish", "failure", "conflict")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.publishes.WithLabelValues("republish", "failure", "not_found")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.publishes.WithLabelValues("unpublish", "failure", "circuit_open")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.publishes.WithLabelValues("save_and_publish", "failure", "other")))
+	assert.Equal(t, 5, testutil.CollectAndCount(m.publishDuration))
+}
+
+func TestObserveStage(t *testing.T) {
+	m := NewMetrics()
+
+	m.ObserveStage(annotations.StageDraftRead, 10*time.Millisecond, nil)
+	m.ObserveStage(annotations.StageUPPPublish, time.Second, errors.New("eek"))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(m.stageDuration))
+	count, err := testutil.GatherAndCount(m.registry, "annotations_publisher_publish_stage_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestTransportCountsResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	m := NewMetrics()
+	client := &http.Client{Transport: m.Transport("upp-publish", http.DefaultTransport)}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	_, err = client.Get("http://localhost:1")
+	require.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.downstreamResponses.WithLabelValues("upp-publish", "503")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.downstreamResponses.WithLabelValues("upp-publish", "error")))
+}
+
+func TestHandler(t *testing.T) {
+	m := NewMetrics()
+	m.ObservePublish(annotations.PublishAttempt{FromStore: true})
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), `annotations_publisher_publishes_total{error="none",operation="publish_from_store",outcome="success"} 1`))
+	assert.True(t, strings.Contains(w.Body.String(), "go_goroutines"))
+}
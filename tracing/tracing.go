@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TransactionIDKey links a span to the FT transaction ID of the request it is part of
+	TransactionIDKey = attribute.Key("ft.transaction_id")
+	// UUIDKey is the UUID of the content whose annotations are published
+	UUIDKey = attribute.Key("ft.content_uuid")
+)
+
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterNone   = "none"
+)
+
+// Config selects where spans are exported to
+type Config struct {
+	ServiceName string
+	// Exporter is otlp, stdout or none. No spans are recorded with none, but trace context headers are still propagated.
+	Exporter string
+	// OTLPEndpoint is the host and port of the OTLP HTTP collector. The OTEL_EXPORTER_OTLP_* environment variables are used if empty.
+	OTLPEndpoint string
+	// File is where the stdout exporter writes spans, one JSON object per span. Spans are written to stdout if empty.
+	File string
+	// SampleRatio is the fraction of traces started by this service which are recorded. Traces started by callers are recorded if the caller recorded them.
+	SampleRatio float64
+}
+
+// Setup installs the global tracer provider and the W3C trace context propagator.
+// The returned function flushes the spans which have not been exported yet, and must be called before exiting.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	var file io.Closer
+	switch config.Exporter {
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if config.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		var w io.Writer = os.Stdout
+		if config.File != "" {
+			f, openErr := os.OpenFile(config.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+			if openErr != nil {
+				return nil, openErr
+			}
+			w, file = f, f
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %v", config.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", config.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			file.Close()
+		}
+		return err
+	}, nil
+}
+
+// Start starts a span of an operation on the annotations of a piece of content, linked to the transaction ID of ctx
+func Start(ctx context.Context, tracer trace.Tracer, name string, uuid string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	txid, _ := tid.GetTransactionIDFromContext(ctx)
+	opts = append(opts, trace.WithAttributes(TransactionIDKey.String(txid), UUIDKey.String(uuid)))
+	return tracer.Start(ctx, name, opts...)
+}
+
+// End records err as the outcome of the span, if it failed, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract returns a copy of ctx holding the trace context sent by the caller of the request, if there was one
+func Extract(ctx context.Context, r *http.Request) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+}
+
+// Transport returns a http.RoundTripper which sends the trace context of every request to the downstream service in the traceparent header
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return &transport{next: next}
+}
+
+type transport struct {
+	next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetupStdoutExporter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "spans.jsonl")
+	shutdown, err := Setup(context.Background(), Config{ServiceName: "test-annotations-publisher", Exporter: ExporterStdout, File: file, SampleRatio: 1})
+	require.NoError(t, err)
+
+	ctx := tid.TransactionAwareContext(context.Background(), "tid_test")
+	_, span := Start(ctx, otel.Tracer("test"), "Publish", "a-valid-uuid")
+	End(span, errors.New("eek"))
+	require.NoError(t, shutdown(context.Background()))
+
+	spans, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(spans), `"Name":"Publish"`))
+	assert.True(t, strings.Contains(string(spans), `"Value":"tid_test"`))
+	assert.True(t, strings.Contains(string(spans), `"Value":"a-valid-uuid"`))
+	assert.True(t, strings.Contains(string(spans), `"Description":"eek"`))
+	assert.True(t, strings.Contains(string(spans), "test-annotations-publisher"))
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Exporter: "zipkin"})
+	assert.EqualError(t, err, "unknown tracing exporter zipkin")
+}
+
+func TestSetupNoExporter(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterNone})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestTransportSendsTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(tracetest.NewSpanRecorder()))
+	ctx, span := provider.Tracer("test").Start(context.Background(), "Publish")
+	defer span.End()
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: Transport(http.DefaultTransport)}).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Empty(t, req.Header.Get("traceparent"), "the request given to the transport should not be modified")
+	require.NotEmpty(t, traceparent)
+	assert.True(t, strings.Contains(traceparent, span.SpanContext().TraceID().String()))
+	assert.True(t, strings.Contains(traceparent, span.SpanContext().SpanID().String()))
+}
+
+func TestExtract(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	req := httptest.NewRequest(http.MethodPost, "/drafts/content/a-valid-uuid/annotations/publish", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	sc := trace.SpanContextFromContext(Extract(context.Background(), req))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	assert.True(t, sc.IsRemote())
+	assert.True(t, sc.IsSampled())
+}